@@ -0,0 +1,247 @@
+// Package admin 是 APISIX Admin API 的类型化客户端
+//
+// 服务注册使用的就是这个客户端，运维工具可以直接复用它来管理上游、路由、服务、
+// 消费者等资源，而不需要自己拼接请求。
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 客户端配置默认值
+const (
+	DefaultTimeout          = 5 * time.Second
+	DefaultRetryCount       = 3
+	DefaultRetryWaitTime    = 500 * time.Millisecond
+	DefaultRetryMaxWaitTime = 2 * time.Second
+)
+
+// Admin API 版本，优先根据响应头 Server: APISIX/x.y.z 识别，没有该响应头时根据响应格式识别
+const (
+	VersionUnknown = 0
+	VersionV2      = 2 // APISIX 2.x，响应格式为 {"action": ..., "node": {"value": {...}}}
+	VersionV3      = 3 // APISIX 3.x，响应格式为 {"value": {...}} 或 {"total": n, "list": [...]}
+)
+
+// 资源类型，与 URL 中的路径段一致
+const (
+	ResourceUpstreams     = "upstreams"
+	ResourceRoutes        = "routes"
+	ResourceServices      = "services"
+	ResourceConsumers     = "consumers"
+	ResourceSSLs          = "ssls"
+	ResourcePluginConfigs = "plugin_configs"
+	ResourceGlobalRules   = "global_rules"
+	ResourceStreamRoutes  = "stream_routes"
+)
+
+// Client 是 APISIX Admin API 客户端，可以在多个 goroutine 中并发使用
+type Client struct {
+	baseURL string
+	apiKey  string
+	client  *resty.Client
+	version atomic.Int32 // 已识别的 Admin API 版本
+}
+
+// Option 客户端配置项
+type Option func(*Client)
+
+// WithTimeout 设置单次请求超时
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.client.SetTimeout(timeout)
+	}
+}
+
+// WithRetry 设置请求失败后的重试次数和等待时间
+func WithRetry(count int, waitTime, maxWaitTime time.Duration) Option {
+	return func(c *Client) {
+		c.client.
+			SetRetryCount(count).
+			SetRetryWaitTime(waitTime).
+			SetRetryMaxWaitTime(maxWaitTime)
+	}
+}
+
+// WithTLSConfig 设置访问 HTTPS Admin API 时使用的 TLS 配置
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.client.SetTLSClientConfig(config)
+	}
+}
+
+// New 创建 Admin API 客户端
+// baseURL 为 Admin API 地址，例如 http://127.0.0.1:9180/apisix/admin
+func New(baseURL, apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client: resty.New().
+			SetTimeout(DefaultTimeout).
+			SetRetryCount(DefaultRetryCount).
+			SetRetryWaitTime(DefaultRetryWaitTime).
+			SetRetryMaxWaitTime(DefaultRetryMaxWaitTime),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// BaseURL 返回 Admin API 地址
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Version 返回已识别的 Admin API 版本，尚未识别时为 VersionUnknown
+func (c *Client) Version() int {
+	return int(c.version.Load())
+}
+
+// Upstreams 上游
+func (c *Client) Upstreams() *Resource[Upstream] {
+	return newResource[Upstream](c, ResourceUpstreams)
+}
+
+// Routes 路由
+func (c *Client) Routes() *Resource[Route] {
+	return newResource[Route](c, ResourceRoutes)
+}
+
+// Services 服务
+func (c *Client) Services() *Resource[Service] {
+	return newResource[Service](c, ResourceServices)
+}
+
+// Consumers 消费者，ID 为消费者的 username
+func (c *Client) Consumers() *Resource[Consumer] {
+	return newResource[Consumer](c, ResourceConsumers)
+}
+
+// SSLs 证书
+func (c *Client) SSLs() *Resource[SSL] {
+	return newResource[SSL](c, ResourceSSLs)
+}
+
+// PluginConfigs 插件配置
+func (c *Client) PluginConfigs() *Resource[PluginConfig] {
+	return newResource[PluginConfig](c, ResourcePluginConfigs)
+}
+
+// GlobalRules 全局规则
+func (c *Client) GlobalRules() *Resource[GlobalRule] {
+	return newResource[GlobalRule](c, ResourceGlobalRules)
+}
+
+// StreamRoutes 四层路由
+func (c *Client) StreamRoutes() *Resource[StreamRoute] {
+	return newResource[StreamRoute](c, ResourceStreamRoutes)
+}
+
+// do 发送请求，非 2xx 响应返回 *APIError
+func (c *Client) do(ctx context.Context, method, resource, path string, body interface{}) (*resty.Response, error) {
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-KEY", c.apiKey)
+
+	if body != nil {
+		req.SetHeader("Content-Type", "application/json").
+			SetBody(body)
+	}
+
+	resp, err := req.Execute(method, c.baseURL+path)
+	if err != nil {
+		return nil, fmt.Errorf("请求 APISIX Admin API 失败: %w", err)
+	}
+
+	if c.version.Load() == VersionUnknown {
+		c.observeVersion(sniffVersion(resp))
+	}
+
+	if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
+		return resp, newAPIError(resp, resource)
+	}
+
+	return resp, nil
+}
+
+// observeVersion 记录识别到的 Admin API 版本
+func (c *Client) observeVersion(version int) {
+	if version != VersionUnknown {
+		c.version.Store(int32(version))
+	}
+}
+
+// DetectVersion 识别 Admin API 版本，识别成功后结果会被缓存，不会重复请求
+func (c *Client) DetectVersion(ctx context.Context) (int, error) {
+	if version := c.Version(); version != VersionUnknown {
+		return version, nil
+	}
+
+	// 任意一次请求都可以识别版本，这里选择路由列表并限制数量，3.0 之前的版本会忽略分页参数
+	if _, err := c.do(ctx, http.MethodGet, ResourceRoutes, resourcePath(ResourceRoutes, "")+"?page=1&page_size=10", nil); err != nil {
+		return VersionUnknown, err
+	}
+
+	if version := c.Version(); version != VersionUnknown {
+		return version, nil
+	}
+	return VersionUnknown, fmt.Errorf("无法识别 APISIX Admin API 版本")
+}
+
+// sniffVersion 根据响应识别 Admin API 版本
+func sniffVersion(resp *resty.Response) int {
+	if server := resp.Header().Get("Server"); strings.HasPrefix(server, "APISIX/") {
+		major, _, _ := strings.Cut(strings.TrimPrefix(server, "APISIX/"), ".")
+		switch major {
+		case "2":
+			return VersionV2
+		case "3":
+			return VersionV3
+		}
+	}
+
+	var shape map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Body(), &shape); err != nil {
+		return VersionUnknown
+	}
+
+	// 2.x 的每个响应都带有 action 字段，包括 404
+	if _, ok := shape["action"]; ok {
+		return VersionV2
+	}
+	if _, ok := shape["node"]; ok {
+		return VersionV2
+	}
+	for _, key := range []string{"value", "list", "total"} {
+		if _, ok := shape[key]; ok {
+			return VersionV3
+		}
+	}
+
+	return VersionUnknown
+}
+
+// resourcePath 拼接资源路径，id 为空时表示资源集合
+func resourcePath(resource, id string, subPath ...string) string {
+	path := "/" + resource
+	if id != "" {
+		path += "/" + url.PathEscape(id)
+	}
+	for _, p := range subPath {
+		path += "/" + url.PathEscape(p)
+	}
+	return path
+}
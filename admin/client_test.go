@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		body    string
+		want    int
+		wantErr bool
+	}{
+		{name: "2.x Server 头", server: "APISIX/2.15.0", body: `{"total": 0, "list": []}`, want: VersionV2},
+		{name: "3.x Server 头", server: "APISIX/3.9.0", body: `{"action": "get"}`, want: VersionV3},
+		{name: "2.x action 字段", body: `{"action": "get", "count": 0}`, want: VersionV2},
+		{name: "2.x node 字段", body: `{"node": {"dir": true, "key": "/apisix/routes"}}`, want: VersionV2},
+		{name: "3.x 列表", body: `{"total": 0, "list": []}`, want: VersionV3},
+		{name: "无法识别", server: "openresty", body: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.server != "" {
+					w.Header().Set("Server", tt.server)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := New(srv.URL+"/apisix/admin", "")
+			got, err := c.DetectVersion(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectVersion() error = %v，wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectVersion() = %d，期望 %d", got, tt.want)
+			}
+			if got != VersionUnknown && c.Version() != got {
+				t.Errorf("Version() = %d，识别结果没有被缓存", c.Version())
+			}
+		})
+	}
+}
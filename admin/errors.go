@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// APIError 是 APISIX Admin API 返回非预期状态码时的错误
+type APIError struct {
+	StatusCode int    // HTTP 状态码
+	ErrorMsg   string // APISIX 返回的 error_msg，无法解析时为原始响应
+	Method     string // 请求方法
+	Path       string // 请求路径，例如 /apisix/admin/upstreams/1
+	Resource   string // 资源类型，例如 upstreams、routes
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("APISIX Admin API %s %s 失败，状态码: %d, 错误: %s",
+		e.Method, e.Path, e.StatusCode, e.ErrorMsg)
+}
+
+// IsNotFound 判断错误是否为资源不存在(404)
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized 判断错误是否为认证失败(401)或无权限(403)，通常是 ApiKey 错误
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized) || hasStatus(err, http.StatusForbidden)
+}
+
+// IsConflict 判断错误是否为资源冲突(409)，例如删除仍被路由引用的上游
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// hasStatus 判断错误链中是否有指定状态码的 APIError
+func hasStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+// newAPIError 根据非预期的响应构造 APIError
+func newAPIError(resp *resty.Response, resource string) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode(),
+		Resource:   resource,
+	}
+
+	if req := resp.Request; req != nil {
+		apiErr.Method = req.Method
+		if u, err := url.Parse(req.URL); err == nil {
+			apiErr.Path = u.Path
+		} else {
+			apiErr.Path = req.URL
+		}
+	}
+
+	// APISIX 的错误信息位于 error_msg，未找到资源时部分版本使用 message
+	var body struct {
+		ErrorMsg string `json:"error_msg"`
+		Message  string `json:"message"`
+	}
+	if err := json.Unmarshal(resp.Body(), &body); err == nil {
+		apiErr.ErrorMsg = body.ErrorMsg
+		if apiErr.ErrorMsg == "" {
+			apiErr.ErrorMsg = body.Message
+		}
+	}
+	if apiErr.ErrorMsg == "" {
+		apiErr.ErrorMsg = resp.String()
+	}
+
+	return apiErr
+}
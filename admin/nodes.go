@@ -0,0 +1,207 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Node 上游节点
+type Node struct {
+	Host     string                 `json:"host"`
+	Port     int                    `json:"port"`
+	Weight   int                    `json:"weight"`
+	Priority int                    `json:"priority,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Key 返回节点在哈希格式中的键 host:port，IPv6 地址会加上方括号，例如 [::1]:8080
+// 所有节点键都应该通过这个方法生成，保证比较时格式一致
+func (n Node) Key() string {
+	if n.Port == 0 {
+		if strings.Contains(n.Host, ":") {
+			return "[" + n.Host + "]"
+		}
+		return n.Host
+	}
+	return net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+}
+
+// Nodes 上游节点列表
+//
+// APISIX 支持两种格式：哈希格式 {"host:port": weight} 和数组格式
+// [{"host": ..., "port": ..., "weight": ...}]。解析时两种格式都能识别，
+// 序列化时保持读取时的格式。
+type Nodes struct {
+	Items []Node
+	Array bool // 是否为数组格式
+}
+
+// List 返回全部节点，n 为 nil 时返回 nil
+func (n *Nodes) List() []Node {
+	if n == nil {
+		return nil
+	}
+	return n.Items
+}
+
+// Find 按 host:port 查找节点，n 为 nil 时返回 false
+func (n *Nodes) Find(key string) (Node, bool) {
+	for _, node := range n.List() {
+		if node.Key() == key {
+			return node, true
+		}
+	}
+	return Node{}, false
+}
+
+// MarshalJSON 按原有格式序列化
+func (n Nodes) MarshalJSON() ([]byte, error) {
+	if n.Array {
+		if n.Items == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(n.Items)
+	}
+
+	weights := make(map[string]int, len(n.Items))
+	for _, node := range n.Items {
+		weights[node.Key()] = node.Weight
+	}
+	return json.Marshal(weights)
+}
+
+// UnmarshalJSON 解析哈希或数组格式的节点，任何输入都只会返回错误而不会 panic
+func (n *Nodes) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		*n = Nodes{}
+		return nil
+	}
+
+	switch data[0] {
+	case '[':
+		var raw []rawNode
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("解析节点数组失败: %w", err)
+		}
+
+		items := make([]Node, 0, len(raw))
+		for i, r := range raw {
+			node, err := r.node()
+			if err != nil {
+				return fmt.Errorf("解析第%d个节点失败: %w", i+1, err)
+			}
+			items = append(items, node)
+		}
+		*n = Nodes{Items: items, Array: true}
+		return nil
+
+	case '{':
+		var raw map[string]json.Number
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("解析节点失败: %w", err)
+		}
+
+		// 按键排序，保证相同的输入得到相同的顺序
+		keys := make([]string, 0, len(raw))
+		for key := range raw {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		items := make([]Node, 0, len(raw))
+		for _, key := range keys {
+			weight, err := toInt(raw[key])
+			if err != nil {
+				return fmt.Errorf("节点 %s 权重错误: %w", key, err)
+			}
+			host, port := splitNodeKey(key)
+			items = append(items, Node{Host: host, Port: port, Weight: weight})
+		}
+		*n = Nodes{Items: items}
+		return nil
+	}
+
+	return fmt.Errorf("节点格式错误: 既不是对象也不是数组")
+}
+
+// rawNode 是数组格式中的单个节点，数值字段可能是整数或浮点数
+type rawNode struct {
+	Host     string                 `json:"host"`
+	Port     json.Number            `json:"port"`
+	Weight   json.Number            `json:"weight"`
+	Priority json.Number            `json:"priority"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// node 转换为 Node
+func (r rawNode) node() (Node, error) {
+	if r.Host == "" {
+		return Node{}, fmt.Errorf("缺少 host")
+	}
+
+	node := Node{Host: trimBrackets(r.Host), Metadata: r.Metadata}
+	for _, f := range []struct {
+		name  string
+		value json.Number
+		dst   *int
+	}{
+		{"port", r.Port, &node.Port},
+		{"weight", r.Weight, &node.Weight},
+		{"priority", r.Priority, &node.Priority},
+	} {
+		if f.value == "" {
+			continue
+		}
+		v, err := toInt(f.value)
+		if err != nil {
+			return Node{}, fmt.Errorf("%s 错误: %w", f.name, err)
+		}
+		*f.dst = v
+	}
+
+	return node, nil
+}
+
+// toInt 把 JSON 数字转换为 int，兼容 1.0 这样的浮点写法
+func toInt(num json.Number) (int, error) {
+	if v, err := strconv.ParseInt(string(num), 10, 0); err == nil {
+		return int(v), nil
+	}
+
+	f, err := strconv.ParseFloat(string(num), 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) || f > math.MaxInt32 || f < math.MinInt32 {
+		return 0, fmt.Errorf("不是有效的整数: %s", num)
+	}
+	return int(f), nil
+}
+
+// splitNodeKey 拆分哈希格式中的 host:port，没有端口时端口为 0
+func splitNodeKey(key string) (string, int) {
+	host, portStr, err := net.SplitHostPort(key)
+	if err != nil {
+		return trimBrackets(key), 0
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return trimBrackets(key), 0
+	}
+	return host, port
+}
+
+// trimBrackets 去掉 IPv6 地址两侧的方括号
+func trimBrackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// nodeSeeds 哈希和数组格式的节点，包括 APISIX 实际返回过的写法和常见的异常输入
+var nodeSeeds = []string{
+	`{"10.0.0.1:8080": 1, "10.0.0.2:8080": 0}`,
+	`{"[::1]:8080": 1.0}`,
+	`{"10.0.0.1": 1}`,
+	`{"10.0.0.1:8080": 1e400}`,
+	`{"10.0.0.1:8080": "1"}`,
+	`[{"host": "10.0.0.1", "port": 8080, "weight": 1, "priority": -1, "metadata": {"zone": "a"}}]`,
+	`[{"host": "[::1]", "port": 8080.0, "weight": 1}]`,
+	`[{"host": "", "port": 8080}]`,
+	`[{"host": "10.0.0.1", "port": 1.5}]`,
+	`[null, 1, "x"]`,
+	`[]`,
+	`{}`,
+	`null`,
+	`"10.0.0.1:8080"`,
+	``,
+}
+
+func FuzzNodesUnmarshalJSON(f *testing.F) {
+	for _, seed := range nodeSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var nodes Nodes
+		if err := nodes.UnmarshalJSON(data); err != nil {
+			return
+		}
+
+		for _, node := range nodes.List() {
+			if _, ok := nodes.Find(node.Key()); !ok {
+				t.Fatalf("找不到刚解析出的节点 %s", node.Key())
+			}
+		}
+
+		// 解析成功的节点序列化后应该能再次解析
+		out, err := json.Marshal(nodes)
+		if err != nil {
+			t.Fatalf("序列化节点失败: %v", err)
+		}
+		var again Nodes
+		if err := json.Unmarshal(out, &again); err != nil {
+			t.Fatalf("重新解析 %s 失败: %v", out, err)
+		}
+		if again.Array != nodes.Array {
+			t.Fatalf("节点格式在序列化后发生变化: %s", out)
+		}
+	})
+}
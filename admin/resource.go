@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Resource 是某一类 Admin API 资源的增删改查操作
+type Resource[T any] struct {
+	client *Client
+	name   string
+}
+
+// newResource 创建资源操作对象
+func newResource[T any](client *Client, name string) *Resource[T] {
+	return &Resource[T]{client: client, name: name}
+}
+
+// item 是 Admin API 返回的单个资源
+type item struct {
+	Key           string          `json:"key"`
+	Value         json.RawMessage `json:"value"`
+	CreatedIndex  int64           `json:"createdIndex"`
+	ModifiedIndex int64           `json:"modifiedIndex"`
+}
+
+// envelope 是 Admin API 响应的外层结构
+// v3 单个资源为 {"key": ..., "value": ...}，列表为 {"total": n, "list": [...]}
+// v2 单个资源为 {"node": {"key": ..., "value": ...}}，列表为 {"node": {"dir": true, "nodes": [...]}}
+type envelope struct {
+	item
+	Node  *dirItem `json:"node"`
+	Total int      `json:"total"`
+	List  []item   `json:"list"`
+}
+
+// dirItem 是 v2 响应中的 node，单个资源时带有 value，列表时带有 nodes
+type dirItem struct {
+	item
+	Dir   bool   `json:"dir"`
+	Nodes []item `json:"nodes"`
+}
+
+// value 返回单个资源的内容，兼容 v2 和 v3
+func (e *envelope) value() json.RawMessage {
+	if e.Node != nil && len(e.Node.Value) > 0 {
+		return e.Node.Value
+	}
+	return e.Value
+}
+
+// items 返回列表中的全部资源，兼容 v2 和 v3
+func (e *envelope) items() []item {
+	if e.Node != nil && (e.Node.Dir || len(e.Node.Nodes) > 0) {
+		return e.Node.Nodes
+	}
+	return e.List
+}
+
+// Get 获取单个资源
+func (r *Resource[T]) Get(ctx context.Context, id string) (*T, error) {
+	resp, err := r.client.do(ctx, http.MethodGet, r.name, resourcePath(r.name, id), nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeItem(resp.Body())
+}
+
+// List 获取全部资源
+func (r *Resource[T]) List(ctx context.Context) ([]T, error) {
+	resp, err := r.client.do(ctx, http.MethodGet, r.name, resourcePath(r.name, ""), nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeList(resp.Body())
+}
+
+// Put 创建或整体替换资源，id 为空时提交到资源集合（例如消费者以 username 作为标识）
+func (r *Resource[T]) Put(ctx context.Context, id string, obj *T) (*T, error) {
+	resp, err := r.client.do(ctx, http.MethodPut, r.name, resourcePath(r.name, id), obj)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeItem(resp.Body())
+}
+
+// Patch 按 APISIX 的合并语义更新资源，patch 中值为 nil 的字段会被删除
+func (r *Resource[T]) Patch(ctx context.Context, id string, patch interface{}) (*T, error) {
+	resp, err := r.client.do(ctx, http.MethodPatch, r.name, resourcePath(r.name, id), patch)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeItem(resp.Body())
+}
+
+// PatchPath 整体替换资源的某个子路径，例如 PatchPath(ctx, id, value, "nodes")
+func (r *Resource[T]) PatchPath(ctx context.Context, id string, value interface{}, subPath ...string) (*T, error) {
+	resp, err := r.client.do(ctx, http.MethodPatch, r.name, resourcePath(r.name, id, subPath...), value)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeItem(resp.Body())
+}
+
+// Delete 删除资源
+func (r *Resource[T]) Delete(ctx context.Context, id string) error {
+	_, err := r.client.do(ctx, http.MethodDelete, r.name, resourcePath(r.name, id), nil)
+	return err
+}
+
+// decodeItem 解析单个资源响应
+func (r *Resource[T]) decodeItem(body []byte) (*T, error) {
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, fmt.Errorf("解析%s响应失败: %w", r.name, err)
+	}
+
+	// 代理返回的错误页或不完整的响应可能是 200，但不包含资源内容
+	value := env.value()
+	if !isObject(value) {
+		return nil, fmt.Errorf("%s响应格式错误: 缺少 value", r.name)
+	}
+
+	obj := new(T)
+	if err := json.Unmarshal(value, obj); err != nil {
+		return nil, fmt.Errorf("解析%s失败: %w", r.name, err)
+	}
+	return obj, nil
+}
+
+// decodeList 解析资源列表响应
+func (r *Resource[T]) decodeList(body []byte) ([]T, error) {
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, fmt.Errorf("解析%s列表响应失败: %w", r.name, err)
+	}
+
+	items := env.items()
+	list := make([]T, 0, len(items))
+	for _, it := range items {
+		// 2.x 的目录中可能包含占位用的空节点
+		if !isObject(it.Value) {
+			continue
+		}
+
+		var obj T
+		if err := json.Unmarshal(it.Value, &obj); err != nil {
+			return nil, fmt.Errorf("解析%s失败: %w", r.name, err)
+		}
+		list = append(list, obj)
+	}
+	return list, nil
+}
+
+// isObject 判断 JSON 是否为对象
+func isObject(raw json.RawMessage) bool {
+	for _, b := range raw {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
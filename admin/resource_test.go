@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// envelopeSeeds v2 和 v3 的单个资源、列表以及异常响应
+var envelopeSeeds = []string{
+	// v3 单个资源，哈希格式和数组格式的节点
+	`{"key": "/apisix/upstreams/1", "value": {"id": "1", "nodes": {"10.0.0.1:8080": 1}}}`,
+	`{"key": "/apisix/upstreams/1", "value": {"id": "1", "nodes": [{"host": "10.0.0.1", "port": 8080, "weight": 1}]}}`,
+	// v3 列表
+	`{"total": 1, "list": [{"key": "/apisix/upstreams/1", "value": {"nodes": {"[::1]:80": 2}}}]}`,
+	// v2 单个资源和目录
+	`{"action": "get", "node": {"key": "/apisix/upstreams/1", "value": {"nodes": {"10.0.0.1:8080": 1}}}}`,
+	`{"action": "get", "node": {"dir": true, "key": "/apisix/upstreams", "nodes": [{"key": "/apisix/upstreams/1", "value": {"nodes": [{"host": "10.0.0.1", "port": 80}]}}, {"key": "/apisix/upstreams/init_dir", "value": ""}]}}`,
+	// 代理以 200 返回的错误内容
+	`{"error_msg": "failed to read upstream"}`,
+	`{"message": "Key not found"}`,
+	`<html>502 Bad Gateway</html>`,
+	`{"value": null}`,
+	`{"value": []}`,
+	`{"node": {"value": "x"}, "list": 1}`,
+	`{"value": {"nodes": "10.0.0.1:8080"}}`,
+	``,
+}
+
+func FuzzResourceDecode(f *testing.F) {
+	for _, seed := range envelopeSeeds {
+		f.Add([]byte(seed))
+	}
+
+	r := newResource[Upstream](nil, ResourceUpstreams)
+	f.Fuzz(func(t *testing.T, body []byte) {
+		if upstream, err := r.decodeItem(body); err == nil {
+			inspectNodes(upstream.Nodes)
+		}
+
+		if list, err := r.decodeList(body); err == nil {
+			for i := range list {
+				inspectNodes(list[i].Nodes)
+			}
+		}
+	})
+}
+
+// inspectNodes 调用节点的查询方法，nil 也必须可以安全调用
+func inspectNodes(nodes *Nodes) {
+	for _, node := range nodes.List() {
+		nodes.Find(node.Key())
+	}
+	nodes.Find("10.0.0.1:8080")
+}
+
+// nodeKeys 返回节点的 host:port，用于比较
+func nodeKeys(nodes *Nodes) []string {
+	keys := []string{}
+	for _, node := range nodes.List() {
+		keys = append(keys, node.Key())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestResourceDecodeItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantID    string
+		wantNodes []string
+		wantArray bool
+		wantErr   bool
+	}{
+		{
+			name:      "v3 哈希格式节点",
+			body:      `{"key": "/apisix/upstreams/1", "value": {"id": "1", "nodes": {"10.0.0.1:8080": 1, "10.0.0.2:8080": 2}}}`,
+			wantID:    "1",
+			wantNodes: []string{"10.0.0.1:8080", "10.0.0.2:8080"},
+		},
+		{
+			name:      "v3 数组格式节点",
+			body:      `{"key": "/apisix/upstreams/1", "value": {"id": "1", "nodes": [{"host": "10.0.0.1", "port": 8080, "weight": 1}]}}`,
+			wantID:    "1",
+			wantNodes: []string{"10.0.0.1:8080"},
+			wantArray: true,
+		},
+		{
+			name:      "v2 node.value",
+			body:      `{"action": "get", "node": {"key": "/apisix/upstreams/1", "value": {"id": "1", "nodes": {"10.0.0.1:8080": 1}}}}`,
+			wantID:    "1",
+			wantNodes: []string{"10.0.0.1:8080"},
+		},
+		{name: "缺少 value", body: `{"message": "Key not found"}`, wantErr: true},
+		{name: "value 不是对象", body: `{"value": []}`, wantErr: true},
+	}
+
+	r := newResource[Upstream](nil, ResourceUpstreams)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream, err := r.decodeItem([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeItem() error = %v，wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if upstream.ID != tt.wantID {
+				t.Errorf("ID = %q，期望 %q", upstream.ID, tt.wantID)
+			}
+			if got := nodeKeys(upstream.Nodes); !reflect.DeepEqual(got, tt.wantNodes) {
+				t.Errorf("节点 %v，期望 %v", got, tt.wantNodes)
+			}
+			if upstream.Nodes.Array != tt.wantArray {
+				t.Errorf("Array = %v，期望 %v", upstream.Nodes.Array, tt.wantArray)
+			}
+		})
+	}
+}
+
+func TestResourceDecodeList(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantIDs []string
+	}{
+		{
+			name:    "v3 列表",
+			body:    `{"total": 2, "list": [{"key": "/apisix/upstreams/1", "value": {"id": "1"}}, {"key": "/apisix/upstreams/2", "value": {"id": "2"}}]}`,
+			wantIDs: []string{"1", "2"},
+		},
+		{
+			name:    "v2 目录，跳过 init_dir 占位节点",
+			body:    `{"action": "get", "node": {"dir": true, "key": "/apisix/upstreams", "nodes": [{"key": "/apisix/upstreams/init_dir", "value": ""}, {"key": "/apisix/upstreams/1", "value": {"id": "1", "nodes": [{"host": "10.0.0.1", "port": 80}]}}]}}`,
+			wantIDs: []string{"1"},
+		},
+		{
+			name:    "v2 空目录",
+			body:    `{"action": "get", "node": {"dir": true, "key": "/apisix/upstreams"}}`,
+			wantIDs: []string{},
+		},
+		{
+			name:    "v3 空列表",
+			body:    `{"total": 0, "list": []}`,
+			wantIDs: []string{},
+		},
+	}
+
+	r := newResource[Upstream](nil, ResourceUpstreams)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := r.decodeList([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("decodeList() error = %v", err)
+			}
+			ids := []string{}
+			for _, upstream := range list {
+				ids = append(ids, upstream.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ID %v，期望 %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
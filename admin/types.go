@@ -0,0 +1,134 @@
+package admin
+
+// Timeout 超时配置，单位为秒
+type Timeout struct {
+	Connect float64 `json:"connect,omitempty"`
+	Send    float64 `json:"send,omitempty"`
+	Read    float64 `json:"read,omitempty"`
+}
+
+// Upstream 上游
+type Upstream struct {
+	ID            string                 `json:"id,omitempty"`
+	Name          string                 `json:"name,omitempty"`
+	Desc          string                 `json:"desc,omitempty"`
+	Type          string                 `json:"type,omitempty"`
+	HashOn        string                 `json:"hash_on,omitempty"`
+	Key           string                 `json:"key,omitempty"`
+	Nodes         *Nodes                 `json:"nodes,omitempty"`
+	ServiceName   string                 `json:"service_name,omitempty"`
+	DiscoveryType string                 `json:"discovery_type,omitempty"`
+	Scheme        string                 `json:"scheme,omitempty"`
+	Retries       *int                   `json:"retries,omitempty"`
+	RetryTimeout  float64                `json:"retry_timeout,omitempty"`
+	Timeout       *Timeout               `json:"timeout,omitempty"`
+	Checks        map[string]interface{} `json:"checks,omitempty"`
+	PassHost      string                 `json:"pass_host,omitempty"`
+	UpstreamHost  string                 `json:"upstream_host,omitempty"`
+	Labels        map[string]string      `json:"labels,omitempty"`
+	CreateTime    int64                  `json:"create_time,omitempty"`
+	UpdateTime    int64                  `json:"update_time,omitempty"`
+}
+
+// Route 路由
+type Route struct {
+	ID              string                 `json:"id,omitempty"`
+	Name            string                 `json:"name,omitempty"`
+	Desc            string                 `json:"desc,omitempty"`
+	URI             string                 `json:"uri,omitempty"`
+	URIs            []string               `json:"uris,omitempty"`
+	Methods         []string               `json:"methods,omitempty"`
+	Host            string                 `json:"host,omitempty"`
+	Hosts           []string               `json:"hosts,omitempty"`
+	RemoteAddrs     []string               `json:"remote_addrs,omitempty"`
+	Vars            []interface{}          `json:"vars,omitempty"`
+	Priority        int                    `json:"priority,omitempty"`
+	UpstreamID      string                 `json:"upstream_id,omitempty"`
+	Upstream        *Upstream              `json:"upstream,omitempty"`
+	ServiceID       string                 `json:"service_id,omitempty"`
+	PluginConfigID  string                 `json:"plugin_config_id,omitempty"`
+	Plugins         map[string]interface{} `json:"plugins,omitempty"`
+	Timeout         *Timeout               `json:"timeout,omitempty"`
+	EnableWebsocket bool                   `json:"enable_websocket,omitempty"`
+	Status          *int                   `json:"status,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	CreateTime      int64                  `json:"create_time,omitempty"`
+	UpdateTime      int64                  `json:"update_time,omitempty"`
+}
+
+// Service 服务，可以被多个路由复用的上游和插件配置
+type Service struct {
+	ID              string                 `json:"id,omitempty"`
+	Name            string                 `json:"name,omitempty"`
+	Desc            string                 `json:"desc,omitempty"`
+	UpstreamID      string                 `json:"upstream_id,omitempty"`
+	Upstream        *Upstream              `json:"upstream,omitempty"`
+	Plugins         map[string]interface{} `json:"plugins,omitempty"`
+	Hosts           []string               `json:"hosts,omitempty"`
+	EnableWebsocket bool                   `json:"enable_websocket,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	CreateTime      int64                  `json:"create_time,omitempty"`
+	UpdateTime      int64                  `json:"update_time,omitempty"`
+}
+
+// Consumer 消费者，以 Username 作为标识
+type Consumer struct {
+	Username   string                 `json:"username"`
+	Desc       string                 `json:"desc,omitempty"`
+	GroupID    string                 `json:"group_id,omitempty"`
+	Plugins    map[string]interface{} `json:"plugins,omitempty"`
+	Labels     map[string]string      `json:"labels,omitempty"`
+	CreateTime int64                  `json:"create_time,omitempty"`
+	UpdateTime int64                  `json:"update_time,omitempty"`
+}
+
+// SSL 证书
+type SSL struct {
+	ID         string            `json:"id,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	Cert       string            `json:"cert,omitempty"`
+	Key        string            `json:"key,omitempty"`
+	Certs      []string          `json:"certs,omitempty"`
+	Keys       []string          `json:"keys,omitempty"`
+	SNI        string            `json:"sni,omitempty"`
+	SNIs       []string          `json:"snis,omitempty"`
+	Status     *int              `json:"status,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	CreateTime int64             `json:"create_time,omitempty"`
+	UpdateTime int64             `json:"update_time,omitempty"`
+}
+
+// PluginConfig 插件配置，可以被多个路由引用
+type PluginConfig struct {
+	ID         string                 `json:"id,omitempty"`
+	Desc       string                 `json:"desc,omitempty"`
+	Plugins    map[string]interface{} `json:"plugins"`
+	Labels     map[string]string      `json:"labels,omitempty"`
+	CreateTime int64                  `json:"create_time,omitempty"`
+	UpdateTime int64                  `json:"update_time,omitempty"`
+}
+
+// GlobalRule 全局规则，插件对所有请求生效
+type GlobalRule struct {
+	ID         string                 `json:"id,omitempty"`
+	Plugins    map[string]interface{} `json:"plugins"`
+	CreateTime int64                  `json:"create_time,omitempty"`
+	UpdateTime int64                  `json:"update_time,omitempty"`
+}
+
+// StreamRoute 四层(TCP/UDP)路由
+type StreamRoute struct {
+	ID         string                 `json:"id,omitempty"`
+	Desc       string                 `json:"desc,omitempty"`
+	RemoteAddr string                 `json:"remote_addr,omitempty"`
+	ServerAddr string                 `json:"server_addr,omitempty"`
+	ServerPort int                    `json:"server_port,omitempty"`
+	SNI        string                 `json:"sni,omitempty"`
+	UpstreamID string                 `json:"upstream_id,omitempty"`
+	Upstream   *Upstream              `json:"upstream,omitempty"`
+	ServiceID  string                 `json:"service_id,omitempty"`
+	Plugins    map[string]interface{} `json:"plugins,omitempty"`
+	Labels     map[string]string      `json:"labels,omitempty"`
+	CreateTime int64                  `json:"create_time,omitempty"`
+	UpdateTime int64                  `json:"update_time,omitempty"`
+}
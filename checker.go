@@ -0,0 +1,218 @@
+package apisix_registration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Checker 健康检查项，例如数据库 ping 或依赖服务检查，返回 nil 表示健康
+type Checker func(ctx context.Context) error
+
+// CheckKind 健康检查项的类型
+type CheckKind int
+
+const (
+	// Liveness 存活检查，失败表示进程需要重启，影响 /livez、/readyz 和健康检查路由
+	Liveness CheckKind = iota
+	// Readiness 就绪检查，失败表示暂时不能接收流量，影响 /readyz 和健康检查路由
+	Readiness
+)
+
+// String 返回检查项类型的名称
+func (k CheckKind) String() string {
+	switch k {
+	case Liveness:
+		return "liveness"
+	case Readiness:
+		return "readiness"
+	default:
+		return fmt.Sprintf("CheckKind(%d)", int(k))
+	}
+}
+
+// 健康检查项的默认参数
+const (
+	// DefaultCheckTimeout 默认单个检查项的超时时间
+	DefaultCheckTimeout = 2 * time.Second
+
+	// DefaultCheckCacheTTL 默认检查结果的缓存时间，缓存期内的请求直接返回上次的结果
+	DefaultCheckCacheTTL = time.Second
+
+	// DefaultLivenessRoute 内部健康检查服务器默认的存活检查路由
+	DefaultLivenessRoute = "/livez"
+
+	// DefaultReadinessRoute 内部健康检查服务器默认的就绪检查路由
+	DefaultReadinessRoute = "/readyz"
+
+	// HealthPathDisabled 作为 LivePath 或 ReadyPath 时关闭对应的路由
+	HealthPathDisabled = "-"
+)
+
+// 检查结果状态
+const (
+	checkStatusOK   = "ok"
+	checkStatusFail = "fail"
+)
+
+// CheckerOption 健康检查项的可选配置
+type CheckerOption func(*checker)
+
+// CheckerWithTimeout 设置检查项的超时时间，默认为 DefaultCheckTimeout
+func CheckerWithTimeout(timeout time.Duration) CheckerOption {
+	return func(c *checker) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// CheckerWithCacheTTL 设置检查结果的缓存时间，默认为 DefaultCheckCacheTTL，小于0时不缓存
+func CheckerWithCacheTTL(ttl time.Duration) CheckerOption {
+	return func(c *checker) {
+		c.cacheTTL = ttl
+	}
+}
+
+// CheckResult 单个检查项的结果
+type CheckResult struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Status    string    `json:"status"`               // ok 或 fail
+	LatencyMs float64   `json:"latency_ms"`           // 最近一次检查的耗时(毫秒)
+	Error     string    `json:"error,omitempty"`      // 最近一次检查的错误
+	LastError string    `json:"last_error,omitempty"` // 最近一次失败的错误，恢复后仍然保留
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// checker 带缓存和超时的健康检查项
+type checker struct {
+	name     string
+	kind     CheckKind
+	check    Checker
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu      sync.Mutex
+	result  CheckResult
+	checked bool
+}
+
+// newChecker 创建健康检查项
+func newChecker(name string, check Checker, kind CheckKind, opts ...CheckerOption) *checker {
+	c := &checker{
+		name:     name,
+		kind:     kind,
+		check:    check,
+		timeout:  DefaultCheckTimeout,
+		cacheTTL: DefaultCheckCacheTTL,
+		result:   CheckResult{Name: name, Kind: kind.String()},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// run 执行检查，缓存期内直接返回上次的结果
+// 同一个检查项同时只会执行一次，并发的请求等待这次执行的结果，避免探测请求压垮依赖服务
+func (c *checker) run(ctx context.Context) CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checked && time.Since(c.result.CheckedAt) < c.cacheTTL {
+		return c.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	// 在单独的 goroutine 中执行，不响应 ctx 的检查项也不会阻塞超过超时时间
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("检查超时(%s): %w", c.timeout, ctx.Err())
+	}
+
+	c.result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	c.result.CheckedAt = time.Now()
+	c.checked = true
+	if err != nil {
+		c.result.Status = checkStatusFail
+		c.result.Error = err.Error()
+		c.result.LastError = err.Error()
+	} else {
+		c.result.Status = checkStatusOK
+		c.result.Error = ""
+	}
+	return c.result
+}
+
+// AddChecker 添加健康检查项，名称相同时替换原有的检查项
+// kind 决定检查项出现在哪些健康检查路由中，opts 可以设置超时时间和结果缓存时间
+// 任意检查项连续 UnhealthyThreshold 次失败时摘除本实例节点，摘除后连续 HealthyThreshold 次全部成功时恢复
+func (s *Service) AddChecker(name string, check Checker, kind CheckKind, opts ...CheckerOption) {
+	if check == nil {
+		s.logger.Warn("健康检查项为nil，已忽略", zap.String("checker", name))
+		return
+	}
+	s.healthSvc.addChecker(name, check, kind, opts...)
+}
+
+// healthReport 健康检查路由返回的内容
+type healthReport struct {
+	Status  string        `json:"status"`
+	Service string        `json:"service"`
+	Time    string        `json:"time"`
+	Checks  []CheckResult `json:"checks,omitempty"`
+}
+
+// healthy 判断所有检查项是否都通过
+func (r *healthReport) healthy() bool {
+	return r.Status == checkStatusOK
+}
+
+// newHealthReport 汇总检查结果，任意一项失败时整体状态为 fail
+func newHealthReport(serviceName string, results []CheckResult) healthReport {
+	report := healthReport{
+		Status:  checkStatusOK,
+		Service: serviceName,
+		Time:    time.Now().Format(time.RFC3339),
+		Checks:  results,
+	}
+	for _, r := range results {
+		if r.Status != checkStatusOK {
+			report.Status = checkStatusFail
+		}
+	}
+	return report
+}
+
+// writeHealthReport 以 JSON 返回检查结果，不健康时状态码为 503，HEAD 请求只返回状态码和响应头
+func writeHealthReport(w http.ResponseWriter, r *http.Request, report healthReport) {
+	body, _ := json.Marshal(report)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if report.healthy() {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
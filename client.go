@@ -1,76 +1,96 @@
 package apisix_registration
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/linabellbiu/apisix-registration/admin"
 	"go.uber.org/zap"
 )
 
-// 客户端配置默认值
+// 写入节点后的复核参数，用于发现并修复多副本并发创建上游时的覆盖
 const (
-	defaultTimeout          = 5 * time.Second
-	defaultRetryCount       = 3
-	defaultRetryWaitTime    = 500 * time.Millisecond
-	defaultRetryMaxWaitTime = 2 * time.Second
+	defaultSettleRounds   = 3
+	defaultSettleInterval = 200 * time.Millisecond
+	defaultSettleAttempts = 5
 )
 
-// apisixClient 是与 APISIX Admin API 交互的客户端
+// apisixClient 在 admin.Client 之上封装服务注册需要的上游、节点和路由操作
 type apisixClient struct {
-	client *resty.Client
+	admin  *admin.Client
 	logger *zap.Logger
 }
 
 // newAPIClient 创建一个新的 APISIX 客户端
-func newAPIClient(logger *zap.Logger) *apisixClient {
-	client := resty.New().
-		SetTimeout(defaultTimeout).
-		SetRetryCount(defaultRetryCount).
-		SetRetryWaitTime(defaultRetryWaitTime).
-		SetRetryMaxWaitTime(defaultRetryMaxWaitTime)
-
+func newAPIClient(adminClient *admin.Client, logger *zap.Logger) *apisixClient {
 	return &apisixClient{
-		client: client,
+		admin:  adminClient,
 		logger: logger,
 	}
 }
 
 // checkUpstreamExists 检查上游是否存在
-func (c *apisixClient) checkUpstreamExists(adminAPI, apiKey, upstreamID string) (bool, error) {
-	url := fmt.Sprintf("%s/upstreams/%s", adminAPI, upstreamID)
-
-	resp, err := c.client.R().
-		SetHeader("X-API-KEY", apiKey).
-		Get(url)
-
-	if err != nil {
-		return false, fmt.Errorf("检查上游请求失败: %w", err)
-	}
+func (c *apisixClient) checkUpstreamExists(ctx context.Context, upstreamID string) (bool, error) {
+	_, err := c.admin.Upstreams().Get(ctx, upstreamID)
 
 	// 如果状态码是404，表示上游不存在
-	if resp.StatusCode() == http.StatusNotFound {
+	if admin.IsNotFound(err) {
 		return false, nil
 	}
 
-	// 如果状态码是200，表示上游存在
-	if resp.StatusCode() == http.StatusOK {
-		c.logger.Info("上游已存在", zap.String("upstream_id", upstreamID))
-		return true, nil
+	if err != nil {
+		return false, fmt.Errorf("检查上游失败: %w", err)
 	}
 
-	// 其他状态码视为错误
-	return false, fmt.Errorf("检查上游失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
+	c.logger.Info("上游已存在", zap.String("upstream_id", upstreamID))
+	return true, nil
 }
 
 // createUpstream 创建上游，如果上游已存在则添加节点
-func (c *apisixClient) createUpstream(adminAPI, apiKey, upstreamID, name, host string, port int) error {
-	nodeKey := fmt.Sprintf("%s:%d", host, port)
+//
+// APISIX 的 PUT 会整体覆盖上游，多个副本同时启动时可能都认为上游不存在，
+// 后写入的副本会把先写入副本的节点覆盖掉。因此写入之后需要多次复核本节点
+// 是否仍在上游中，发现丢失就重新读取并合并节点，直到节点稳定存在。
+func (c *apisixClient) createUpstream(ctx context.Context, upstream Upstream, name string, node admin.Node) error {
+	upstreamID := upstream.Id
+	nodeKey := node.Key()
+
+	for attempt := 1; ; attempt++ {
+		if err := c.putOrAddNode(ctx, upstream, name, node); err != nil {
+			return err
+		}
+
+		settled, err := c.settleNode(ctx, upstreamID, nodeKey)
+		if err != nil {
+			return err
+		}
+		if settled {
+			return nil
+		}
+
+		if attempt >= defaultSettleAttempts {
+			return fmt.Errorf("节点在上游中始终无法保持，已重试%d次", attempt)
+		}
+
+		c.logger.Warn("节点被并发写入覆盖，重新合并节点",
+			zap.String("upstream_id", upstreamID),
+			zap.String("node", nodeKey),
+			zap.Int("attempt", attempt))
+	}
+}
+
+// putOrAddNode 上游不存在时创建上游，已存在时添加节点
+func (c *apisixClient) putOrAddNode(ctx context.Context, upstream Upstream, name string, node admin.Node) error {
+	upstreamID := upstream.Id
+	nodeKey := node.Key()
 
 	// 首先检查上游是否存在
-	exists, err := c.checkUpstreamExists(adminAPI, apiKey, upstreamID)
+	exists, err := c.checkUpstreamExists(ctx, upstreamID)
 	if err != nil {
 		return err
 	}
@@ -81,159 +101,460 @@ func (c *apisixClient) createUpstream(adminAPI, apiKey, upstreamID, name, host s
 			zap.String("upstream_id", upstreamID),
 			zap.String("node", nodeKey))
 
-		return c.addNodeToUpstream(adminAPI, apiKey, upstreamID, host, port)
+		return c.addNodeToUpstream(ctx, upstreamID, node)
 	}
 
-	// 上游不存在，创建新的上游
-	url := fmt.Sprintf("%s/upstreams/%s", adminAPI, upstreamID)
-
-	data := map[string]interface{}{
-		"name": name,
-		"type": "roundrobin",
-		"nodes": map[string]int{
-			nodeKey: 1,
+	// 上游不存在，创建新的上游，哈希格式无法表达优先级和元数据，此时使用数组格式
+	data := &admin.Upstream{
+		Name: name,
+		Type: upstream.UpstreamTypes,
+		Nodes: &admin.Nodes{
+			Items: []admin.Node{node},
+			Array: needsArrayFormat(node),
 		},
+		Checks: upstream.checks,
+		Scheme: upstream.Scheme,
 	}
-
-	resp, err := c.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("X-API-KEY", apiKey).
-		SetBody(data).
-		Put(url)
-
-	if err != nil {
-		return fmt.Errorf("创建上游请求失败: %w", err)
+	if upstream.UpstreamTypes == UpstreamCHash {
+		data.HashOn = upstream.HashOn
+		data.Key = upstream.Key
 	}
 
-	if resp.StatusCode() != http.StatusCreated && resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("创建上游失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
+	if _, err := c.admin.Upstreams().Put(ctx, upstreamID, data); err != nil {
+		return fmt.Errorf("创建上游失败: %w", err)
 	}
 
 	c.logger.Info("成功创建上游",
 		zap.String("upstream_id", upstreamID),
 		zap.String("name", name),
-		zap.String("host", host),
-		zap.Int("port", port),
+		zap.String("type", upstream.UpstreamTypes),
+		zap.String("scheme", upstream.Scheme),
+		zap.String("host", node.Host),
+		zap.Int("port", node.Port),
+		zap.Int("weight", node.Weight),
 	)
 
 	return nil
 }
 
-// addNodeToUpstream 向现有上游添加节点
-func (c *apisixClient) addNodeToUpstream(adminAPI, apiKey, upstreamID, host string, port int) error {
-	// 获取当前上游信息
-	url := fmt.Sprintf("%s/upstreams/%s", adminAPI, upstreamID)
-	nodeKey := fmt.Sprintf("%s:%d", host, port)
+// settleNode 在一段随机抖动的时间窗口内多次复核节点，全部复核都存在才认为写入稳定
+func (c *apisixClient) settleNode(ctx context.Context, upstreamID, nodeKey string) (bool, error) {
+	return c.settle(ctx, upstreamID, func(nodes *admin.Nodes) bool {
+		_, exists := nodes.Find(nodeKey)
+		return exists
+	})
+}
 
-	resp, err := c.client.R().
-		SetHeader("X-API-KEY", apiKey).
-		Get(url)
+// settle 在一段随机抖动的时间窗口内多次读取节点列表，每次 check 都成立才认为写入稳定
+// 上游不存在时 nodes 为 nil
+func (c *apisixClient) settle(ctx context.Context, upstreamID string, check func(nodes *admin.Nodes) bool) (bool, error) {
+	for i := 0; i < defaultSettleRounds; i++ {
+		if err := sleepContext(ctx, jitter(defaultSettleInterval)); err != nil {
+			return false, err
+		}
+
+		upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+		if err != nil && !admin.IsNotFound(err) {
+			return false, fmt.Errorf("获取上游信息失败: %w", err)
+		}
+		var nodes *admin.Nodes
+		if err == nil {
+			nodes = upstream.Nodes
+		}
+		if !check(nodes) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
 
-	if err != nil {
-		return fmt.Errorf("获取上游信息失败: %w", err)
+// rewriteNodes 读取节点列表，用 update 修改后整体写回，再复核 check 直到修改稳定
+//
+// 数组格式的节点无法按节点合并，APISIX 2.x 也不使用合并语义，只能读取整个节点列表后写回。
+// 其他副本在读取和写回之间修改节点时，后写入的一方会覆盖先写入的一方，因此写回后与创建上游
+// 一样多次复核，发现本次修改被覆盖时重新读取并合并，最多重试 defaultSettleAttempts 次。
+// update 返回 nil 表示无需写入，上游不存在时返回 false。
+func (c *apisixClient) rewriteNodes(ctx context.Context, upstreamID string, update func(nodes *admin.Nodes) interface{}, check func(nodes *admin.Nodes) bool) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+		if admin.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("获取上游信息失败: %w", err)
+		}
+
+		nodes := update(upstream.Nodes)
+		if nodes == nil {
+			return true, nil
+		}
+		if err := c.writeNodes(ctx, upstreamID, nodes); err != nil {
+			return false, err
+		}
+
+		settled, err := c.settle(ctx, upstreamID, check)
+		if err != nil {
+			return false, err
+		}
+		if settled {
+			return true, nil
+		}
+
+		if attempt >= defaultSettleAttempts {
+			return false, fmt.Errorf("节点修改始终被并发写入覆盖，已重试%d次", attempt)
+		}
+
+		c.logger.Warn("节点修改被并发写入覆盖，重新合并节点",
+			zap.String("upstream_id", upstreamID),
+			zap.Int("attempt", attempt))
 	}
+}
 
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("获取上游信息失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
-	}
+// sleepContext 等待指定时长，context 结束时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-	// 解析上游信息
-	var upstreamData map[string]interface{}
-	if err := json.Unmarshal(resp.Body(), &upstreamData); err != nil {
-		return fmt.Errorf("解析上游信息失败: %w", err)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
 
-	// 获取节点信息
-	upstreamValue, ok := upstreamData["value"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("上游数据格式错误")
+// jitter 返回 [d/2, d*3/2) 之间的随机时长，避免多个副本按相同节奏请求
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
 	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
 
-	nodes, ok := upstreamValue["nodes"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("上游节点数据格式错误")
+// addNodeToUpstream 向现有上游添加节点，保持上游原有的节点格式
+//
+// 哈希格式的上游使用 PATCH 的合并语义只提交本节点 {"nodes": {"host:port": weight}}，
+// APISIX 会把它合并进已有的节点列表，不会覆盖运维人员或其他实例对超时、健康检查和
+// 其他节点的修改。注意不能使用 /upstreams/{id}/nodes 子路径，它会整体替换 nodes。
+//
+// 限制：数组格式（设置了 Priority 或 Metadata 时创建的上游都是数组格式）无法按节点合并，
+// APISIX 2.x 也不使用合并语义，这两种情况只能读取整个节点列表后写回，与其他副本的并发修改
+// 仍然可能互相覆盖。rewriteNodes 会在写回后复核并重新合并，把覆盖的窗口缩小到复核期间，
+// 但无法像哈希格式那样完全避免。
+func (c *apisixClient) addNodeToUpstream(ctx context.Context, upstreamID string, node admin.Node) error {
+	nodeKey := node.Key()
+
+	// 获取当前上游信息
+	upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+	if err != nil {
+		return fmt.Errorf("获取上游信息失败: %w", err)
 	}
 
-	// 检查节点是否已存在
-	if _, exists := nodes[nodeKey]; exists {
+	// 节点已存在时不再写入，避免覆盖运维人员调整过的权重
+	if _, exists := upstream.Nodes.Find(nodeKey); exists {
 		c.logger.Info("节点已存在，无需添加",
 			zap.String("upstream_id", upstreamID),
 			zap.String("node", nodeKey))
 		return nil
 	}
 
-	// 添加新节点
-	nodes[nodeKey] = float64(1)
+	switch {
+	case upstream.Nodes != nil && upstream.Nodes.Array || c.isLegacy(ctx):
+		var found bool
+		found, err = c.rewriteNodes(ctx, upstreamID, func(nodes *admin.Nodes) interface{} {
+			if _, exists := nodes.Find(nodeKey); exists {
+				return nil
+			}
+			return withNode(nodes, node)
+		}, func(nodes *admin.Nodes) bool {
+			_, exists := nodes.Find(nodeKey)
+			return exists
+		})
+		if err == nil && !found {
+			err = fmt.Errorf("上游不存在: %s", upstreamID)
+		}
+	default:
+		if needsArrayFormat(node) {
+			c.logger.Warn("上游使用哈希格式，节点的优先级和元数据将被忽略",
+				zap.String("upstream_id", upstreamID),
+				zap.String("node", nodeKey))
+		}
+		var found bool
+		found, err = c.patchNode(ctx, upstreamID, nodeKey, node.Weight)
+		if err == nil && !found {
+			err = fmt.Errorf("上游不存在: %s", upstreamID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	c.logger.Info("成功添加节点到上游",
+		zap.String("upstream_id", upstreamID),
+		zap.String("node", nodeKey),
+		zap.Int("weight", node.Weight))
+
+	return nil
+}
+
+// ensureNode 确保节点存在于上游中且权重与期望一致，返回是否进行了修复
+// 上游被删除时重新创建，节点丢失时重新添加，权重被修改时恢复
+func (c *apisixClient) ensureNode(ctx context.Context, upstream Upstream, name string, node admin.Node) (bool, error) {
+	nodeKey := node.Key()
+
+	current, err := c.admin.Upstreams().Get(ctx, upstream.Id)
+	if admin.IsNotFound(err) {
+		c.logger.Warn("上游已丢失，重新创建",
+			zap.String("upstream_id", upstream.Id),
+			zap.String("node", nodeKey))
+		return true, c.createUpstream(ctx, upstream, name, node)
+	}
+	if err != nil {
+		return false, fmt.Errorf("获取上游信息失败: %w", err)
+	}
+
+	existing, ok := current.Nodes.Find(nodeKey)
+	if !ok {
+		c.logger.Warn("节点已丢失，重新添加",
+			zap.String("upstream_id", upstream.Id),
+			zap.String("node", nodeKey))
+		return true, c.addNodeToUpstream(ctx, upstream.Id, node)
+	}
+
+	if existing.Weight == node.Weight {
+		return false, nil
+	}
+
+	c.logger.Warn("节点权重与期望不一致，恢复权重",
+		zap.String("upstream_id", upstream.Id),
+		zap.String("node", nodeKey),
+		zap.Int("current_weight", existing.Weight),
+		zap.Int("weight", node.Weight))
+	return true, c.writeNodeWeight(ctx, upstream.Id, current.Nodes, nodeKey, node.Weight)
+}
 
-	// 更新上游信息
-	updateResp, err := c.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("X-API-KEY", apiKey).
-		SetBody(upstreamValue).
-		Patch(url)
+// setNodeWeight 修改节点权重，上游或节点不存在时返回 false
+func (c *apisixClient) setNodeWeight(ctx context.Context, upstreamID, nodeKey string, weight int) (bool, error) {
+	upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+	if admin.IsNotFound(err) {
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("更新上游请求失败: %w", err)
+		return false, fmt.Errorf("获取上游信息失败: %w", err)
 	}
 
-	if updateResp.StatusCode() != http.StatusOK && updateResp.StatusCode() != http.StatusCreated {
-		return fmt.Errorf("更新上游失败，状态码: %d, 响应: %s", updateResp.StatusCode(), updateResp.String())
+	existing, ok := upstream.Nodes.Find(nodeKey)
+	if !ok {
+		return false, nil
+	}
+	if existing.Weight == weight {
+		return true, nil
 	}
 
-	c.logger.Info("成功添加节点到上游",
+	if err := c.writeNodeWeight(ctx, upstreamID, upstream.Nodes, nodeKey, weight); err != nil {
+		return false, err
+	}
+
+	c.logger.Info("已修改节点权重",
 		zap.String("upstream_id", upstreamID),
-		zap.String("node", nodeKey))
+		zap.String("node", nodeKey),
+		zap.Int("weight", weight))
+	return true, nil
+}
 
-	return nil
+// writeNodeWeight 根据上游当前的节点格式写入节点权重
+// 数组格式和 APISIX 2.x 整体写回节点列表，写回后复核，权重被并发写入覆盖时重新写入
+func (c *apisixClient) writeNodeWeight(ctx context.Context, upstreamID string, nodes *admin.Nodes, nodeKey string, weight int) error {
+	switch {
+	case nodes != nil && nodes.Array || c.isLegacy(ctx):
+		// 节点已被删除时不再加回，复核也视为完成
+		hasWeight := func(nodes *admin.Nodes) bool {
+			n, exists := nodes.Find(nodeKey)
+			return !exists || n.Weight == weight
+		}
+		_, err := c.rewriteNodes(ctx, upstreamID, func(nodes *admin.Nodes) interface{} {
+			if hasWeight(nodes) {
+				return nil
+			}
+			return withNodeWeight(nodes, nodeKey, weight)
+		}, hasWeight)
+		return err
+	default:
+		_, err := c.patchNode(ctx, upstreamID, nodeKey, weight)
+		return err
+	}
 }
 
-// createRoute 创建路由
-func (c *apisixClient) createRoute(adminAPI, apiKey, routeID, name, path, upstreamID string) error {
-	url := fmt.Sprintf("%s/routes/%s", adminAPI, routeID)
+// ensureRoute 确保路由存在，路由丢失时重新创建，返回是否进行了修复
+//
+// 只修复被删除的路由，已存在的路由即使指向其他上游或被修改过也保持不变：
+// 运维人员可能有意调整路由，对账不应该每个周期都把它改回来。
+func (c *apisixClient) ensureRoute(ctx context.Context, route RouteConfig, upstreamID string) (bool, error) {
+	_, err := c.admin.Routes().Get(ctx, route.Id)
+	if err == nil {
+		return false, nil
+	}
+	if !admin.IsNotFound(err) {
+		return false, fmt.Errorf("获取路由信息失败: %w", err)
+	}
 
-	data := map[string]interface{}{
-		"name":        name,
-		"uri":         path,
-		"upstream_id": upstreamID,
+	c.logger.Warn("路由已丢失，重新创建",
+		zap.String("route_id", route.Id),
+		zap.String("upstream_id", upstreamID))
+	return true, c.createRoute(ctx, route, upstreamID)
+}
+
+// isLegacy 判断是否为 APISIX 2.x，版本只会探测一次，探测失败时按 3.x 处理
+func (c *apisixClient) isLegacy(ctx context.Context) bool {
+	version, err := c.admin.DetectVersion(ctx)
+	if err != nil {
+		c.logger.Warn("识别 APISIX Admin API 版本失败，按 3.x 处理", zap.Error(err))
+		return false
 	}
+	return version == admin.VersionV2
+}
 
-	resp, err := c.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("X-API-KEY", apiKey).
-		SetBody(data).
-		Put(url)
+// patchNode 只修改上游中的单个节点，weight 为 nil 时删除该节点
+// 上游不存在时返回 false
+func (c *apisixClient) patchNode(ctx context.Context, upstreamID, nodeKey string, weight interface{}) (bool, error) {
+	data := map[string]interface{}{
+		"nodes": map[string]interface{}{
+			nodeKey: weight,
+		},
+	}
 
+	_, err := c.admin.Upstreams().Patch(ctx, upstreamID, data)
+	if admin.IsNotFound(err) {
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("创建路由请求失败: %w", err)
+		return false, fmt.Errorf("更新上游节点失败: %w", err)
 	}
 
-	if resp.StatusCode() != http.StatusCreated && resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("创建路由失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
+	return true, nil
+}
+
+// createRoute 确保路由存在并指向指定的上游
+//
+// 路由不存在时整体创建；已存在时只 PATCH 本库管理的字段（名称、匹配条件、优先级和上游），
+// 运维人员在路由上添加的插件、标签等配置不会因为实例重启而丢失，字段与配置一致时不写入。
+func (c *apisixClient) createRoute(ctx context.Context, route RouteConfig, upstreamID string) error {
+	current, err := c.admin.Routes().Get(ctx, route.Id)
+	if err != nil && !admin.IsNotFound(err) {
+		return fmt.Errorf("获取路由信息失败: %w", err)
+	}
+
+	if err == nil {
+		if routeMatches(current, route, upstreamID) {
+			return nil
+		}
+		if _, err := c.admin.Routes().Patch(ctx, route.Id, routeFields(route, upstreamID)); err != nil {
+			return fmt.Errorf("更新路由失败: %w", err)
+		}
+		c.logger.Info("已更新路由",
+			zap.String("route_id", route.Id),
+			zap.String("upstream_id", upstreamID))
+		return nil
+	}
+
+	data := &admin.Route{
+		Name:       route.Name,
+		URI:        route.Uri,
+		URIs:       route.Uris,
+		Methods:    route.Methods,
+		Hosts:      route.Hosts,
+		Priority:   route.Priority,
+		UpstreamID: upstreamID,
+	}
+
+	if _, err := c.admin.Routes().Put(ctx, route.Id, data); err != nil {
+		return fmt.Errorf("创建路由失败: %w", err)
 	}
 
 	c.logger.Info("成功创建路由",
-		zap.String("route_id", routeID),
-		zap.String("name", name),
-		zap.String("path", path),
+		zap.String("route_id", route.Id),
+		zap.String("name", route.Name),
+		zap.String("uri", route.Uri),
+		zap.Strings("uris", route.Uris),
 		zap.String("upstream_id", upstreamID),
 	)
 
 	return nil
 }
 
-// deleteUpstream 删除上游
-func (c *apisixClient) deleteUpstream(adminAPI, apiKey, upstreamID string) error {
-	url := fmt.Sprintf("%s/upstreams/%s", adminAPI, upstreamID)
+// routeFields 返回本库管理的路由字段，空值为 null，PATCH 时会删除路由上对应的字段
+func routeFields(route RouteConfig, upstreamID string) map[string]interface{} {
+	fields := map[string]interface{}{
+		"name":        route.Name,
+		"uri":         nil,
+		"uris":        nil,
+		"methods":     nil,
+		"hosts":       nil,
+		"priority":    route.Priority,
+		"upstream_id": upstreamID,
+	}
+	if route.Uri != "" {
+		fields["uri"] = route.Uri
+	}
+	if len(route.Uris) > 0 {
+		fields["uris"] = route.Uris
+	}
+	if len(route.Methods) > 0 {
+		fields["methods"] = route.Methods
+	}
+	if len(route.Hosts) > 0 {
+		fields["hosts"] = route.Hosts
+	}
+	return fields
+}
 
-	resp, err := c.client.R().
-		SetHeader("X-API-KEY", apiKey).
-		Delete(url)
+// routeMatches 判断已有路由中本库管理的字段是否与配置一致
+func routeMatches(current *admin.Route, route RouteConfig, upstreamID string) bool {
+	return current.Name == route.Name &&
+		current.URI == route.Uri &&
+		sameStrings(current.URIs, route.Uris) &&
+		sameStrings(current.Methods, route.Methods) &&
+		sameStrings(current.Hosts, route.Hosts) &&
+		current.Priority == route.Priority &&
+		current.UpstreamID == upstreamID
+}
 
+// sameStrings 判断两个字符串列表是否相同，nil 与空列表视为相同
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// onlyNodes 判断上游中是否只剩 nodeKeys 中的节点，上游不存在时返回 true
+func (c *apisixClient) onlyNodes(ctx context.Context, upstreamID string, nodeKeys []string) (bool, error) {
+	upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+	if admin.IsNotFound(err) {
+		return true, nil
+	}
 	if err != nil {
-		return fmt.Errorf("删除上游请求失败: %w", err)
+		return false, fmt.Errorf("获取上游信息失败: %w", err)
+	}
+
+	for _, n := range upstream.Nodes.List() {
+		if !containsString(nodeKeys, n.Key()) {
+			return false, nil
+		}
 	}
+	return true, nil
+}
 
-	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusNoContent {
-		return fmt.Errorf("删除上游失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
+// deleteUpstream 删除上游
+func (c *apisixClient) deleteUpstream(ctx context.Context, upstreamID string) error {
+	if err := c.admin.Upstreams().Delete(ctx, upstreamID); err != nil {
+		return fmt.Errorf("删除上游失败: %w", err)
 	}
 
 	c.logger.Info("成功删除上游", zap.String("upstream_id", upstreamID))
@@ -241,94 +562,69 @@ func (c *apisixClient) deleteUpstream(adminAPI, apiKey, upstreamID string) error
 }
 
 // deleteRoute 删除路由
-func (c *apisixClient) deleteRoute(adminAPI, apiKey, routeID string) error {
-	url := fmt.Sprintf("%s/routes/%s", adminAPI, routeID)
+func (c *apisixClient) deleteRoute(ctx context.Context, routeID string) error {
+	err := c.admin.Routes().Delete(ctx, routeID)
 
-	resp, err := c.client.R().
-		SetHeader("X-API-KEY", apiKey).
-		Delete(url)
-
-	if err != nil {
-		return fmt.Errorf("删除路由请求失败: %w", err)
+	// 路由不存在时视为已删除
+	if admin.IsNotFound(err) {
+		c.logger.Info("路由不存在，无需删除", zap.String("route_id", routeID))
+		return nil
 	}
 
-	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusNoContent {
-		return fmt.Errorf("删除路由失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
+	if err != nil {
+		return fmt.Errorf("删除路由失败: %w", err)
 	}
 
 	c.logger.Info("成功删除路由", zap.String("route_id", routeID))
 	return nil
 }
 
-// 添加删除节点方法
-func (c *apisixClient) deleteNode(adminAPI, apiKey, upstreamID, node string) error {
+// deleteNode 从上游删除节点，保持上游原有的节点格式，不影响其他配置
+// 哈希格式只提交 {"nodes": {"host:port": null}}，数组格式和 APISIX 2.x 读取节点列表后整体写回并复核
+func (c *apisixClient) deleteNode(ctx context.Context, upstreamID, node string) error {
 	// 首先获取当前上游信息
-	url := fmt.Sprintf("%s/upstreams/%s", adminAPI, upstreamID)
-
-	resp, err := c.client.R().
-		SetHeader("X-API-KEY", apiKey).
-		Get(url)
-
-	if err != nil {
-		return fmt.Errorf("获取上游信息失败: %w", err)
-	}
-
-	if resp.StatusCode() == http.StatusNotFound {
+	upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+	if admin.IsNotFound(err) {
 		c.logger.Info("上游不存在，无需删除节点", zap.String("upstream_id", upstreamID))
 		return nil
 	}
-
-	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("获取上游信息失败，状态码: %d, 响应: %s", resp.StatusCode(), resp.String())
-	}
-
-	// 解析上游信息
-	var upstreamData map[string]interface{}
-	if err := json.Unmarshal(resp.Body(), &upstreamData); err != nil {
-		return fmt.Errorf("解析上游信息失败: %w", err)
-	}
-
-	// 获取节点信息
-	nodes, ok := upstreamData["value"].(map[string]interface{})["nodes"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("上游节点数据格式错误")
+	if err != nil {
+		return fmt.Errorf("获取上游信息失败: %w", err)
 	}
 
 	// 检查节点是否存在
-	if _, exists := nodes[node]; !exists {
+	if _, exists := upstream.Nodes.Find(node); !exists {
 		c.logger.Info("节点不存在，无需删除",
 			zap.String("upstream_id", upstreamID),
 			zap.String("node", node))
 		return nil
 	}
 
-	// 从节点列表中删除指定节点
-	nodes[node] = nil
-
 	// 如果删除后节点列表为空，整个上游也不用保留了
 	// 考虑是否需要这段逻辑,大概率不应该删除整个上游吧
-	//if len(nodes) == 0 {
+	//if len(upstream.Nodes.List()) == 1 {
 	//	c.logger.Info("删除最后一个节点，将删除整个上游",
 	//		zap.String("upstream_id", upstreamID))
-	//	return c.deleteUpstream(adminAPI, apiKey, upstreamID)
+	//	return c.deleteUpstream(ctx, upstreamID)
 	//}
 
-	// 更新上游信息
-	upstreamData["value"].(map[string]interface{})["nodes"] = nodes
-
-	// 更新上游
-	updateResp, err := c.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("X-API-KEY", apiKey).
-		SetBody(upstreamData["value"]).
-		Patch(url)
-
-	if err != nil {
-		return fmt.Errorf("更新上游请求失败: %w", err)
+	switch {
+	case upstream.Nodes.Array || c.isLegacy(ctx):
+		removed := func(nodes *admin.Nodes) bool {
+			_, exists := nodes.Find(node)
+			return !exists
+		}
+		_, err = c.rewriteNodes(ctx, upstreamID, func(nodes *admin.Nodes) interface{} {
+			if removed(nodes) {
+				return nil
+			}
+			return withoutNode(nodes, node)
+		}, removed)
+	default:
+		_, err = c.patchNode(ctx, upstreamID, node, nil)
 	}
-
-	if updateResp.StatusCode() != http.StatusCreated && updateResp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("更新上游失败，状态码: %d, 响应: %s", updateResp.StatusCode(), updateResp.String())
+	if err != nil {
+		return err
 	}
 
 	c.logger.Info("服务下线,踢出节点",
@@ -337,3 +633,198 @@ func (c *apisixClient) deleteNode(adminAPI, apiKey, upstreamID, node string) err
 
 	return nil
 }
+
+// writeNodes 整体写回节点列表
+func (c *apisixClient) writeNodes(ctx context.Context, upstreamID string, nodes interface{}) error {
+	if _, err := c.admin.Upstreams().Patch(ctx, upstreamID, map[string]interface{}{"nodes": nodes}); err != nil {
+		return fmt.Errorf("更新上游失败: %w", err)
+	}
+	return nil
+}
+
+// withNode 返回添加节点后需要整体写回的节点列表，保持原有的节点格式
+func withNode(nodes *admin.Nodes, node admin.Node) interface{} {
+	if nodes != nil && nodes.Array {
+		items := append(append([]admin.Node(nil), nodes.Items...), node)
+		return admin.Nodes{Items: items, Array: true}
+	}
+	weights := nodeWeights(nodes)
+	weights[node.Key()] = node.Weight
+	return weights
+}
+
+// withNodeWeight 返回修改节点权重后需要整体写回的节点列表，保持原有的节点格式
+func withNodeWeight(nodes *admin.Nodes, nodeKey string, weight int) interface{} {
+	if nodes != nil && nodes.Array {
+		items := make([]admin.Node, 0, len(nodes.Items))
+		for _, n := range nodes.Items {
+			if n.Key() == nodeKey {
+				n.Weight = weight
+			}
+			items = append(items, n)
+		}
+		return admin.Nodes{Items: items, Array: true}
+	}
+	weights := nodeWeights(nodes)
+	weights[nodeKey] = weight
+	return weights
+}
+
+// withoutNode 返回删除节点后需要整体写回的节点列表，保持原有的节点格式
+func withoutNode(nodes *admin.Nodes, nodeKey string) interface{} {
+	if nodes != nil && nodes.Array {
+		items := make([]admin.Node, 0, len(nodes.Items))
+		for _, n := range nodes.Items {
+			if n.Key() != nodeKey {
+				items = append(items, n)
+			}
+		}
+		return admin.Nodes{Items: items, Array: true}
+	}
+	// 值为 null 的节点会被 APISIX 删除
+	weights := nodeWeights(nodes)
+	weights[nodeKey] = nil
+	return weights
+}
+
+// nodeWeights 把节点列表转换为哈希格式
+func nodeWeights(nodes *admin.Nodes) map[string]interface{} {
+	weights := make(map[string]interface{}, len(nodes.List())+1)
+	for _, n := range nodes.List() {
+		weights[n.Key()] = n.Weight
+	}
+	return weights
+}
+
+// needsArrayFormat 判断节点是否需要数组格式，哈希格式只能表达权重
+func needsArrayFormat(node admin.Node) bool {
+	return node.Priority != 0 || len(node.Metadata) > 0
+}
+
+// renewLease 把节点心跳时间写入上游标签 lease_{host:port}，上游不存在时忽略
+func (c *apisixClient) renewLease(ctx context.Context, upstreamID, nodeKey string, now time.Time) error {
+	return c.patchLabel(ctx, upstreamID, leaseLabelPrefix+nodeKey, strconv.FormatInt(now.Unix(), 10))
+}
+
+// releaseLease 删除节点的租约标签，上游不存在时忽略
+func (c *apisixClient) releaseLease(ctx context.Context, upstreamID, nodeKey string) error {
+	return c.patchLabel(ctx, upstreamID, leaseLabelPrefix+nodeKey, nil)
+}
+
+// patchLabel 只修改上游中的单个标签，value 为 nil 时删除该标签
+// 与节点一样使用 PATCH 的合并语义，多个实例同时续约不会互相覆盖，APISIX 2.x 读取标签后整体写回
+func (c *apisixClient) patchLabel(ctx context.Context, upstreamID, key string, value interface{}) error {
+	labels := map[string]interface{}{key: value}
+
+	if c.isLegacy(ctx) {
+		upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+		if admin.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("获取上游信息失败: %w", err)
+		}
+		for k, v := range upstream.Labels {
+			if k != key {
+				labels[k] = v
+			}
+		}
+	}
+
+	_, err := c.admin.Upstreams().Patch(ctx, upstreamID, map[string]interface{}{"labels": labels})
+	if admin.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("更新上游标签失败: %w", err)
+	}
+	return nil
+}
+
+// reapStaleNodes 删除租约过期的节点，返回被删除的节点
+//
+// 只处理带有租约标签的节点，运维人员手工添加的节点不受影响；keep 中的节点即使过期也不会删除。
+// 单次最多删除 maxRemovals 个节点，并且永远不会删除上游中的最后一个节点。
+// 节点已不在上游中的租约标签会被直接清理，不计入删除数量。
+func (c *apisixClient) reapStaleNodes(ctx context.Context, upstreamID string, ttl time.Duration, maxRemovals int, keep map[string]bool) ([]string, error) {
+	upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+	if admin.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取上游信息失败: %w", err)
+	}
+
+	type lease struct {
+		nodeKey string
+		beat    time.Time
+	}
+
+	now := time.Now()
+	var expired []lease
+	for key, value := range upstream.Labels {
+		nodeKey, ok := strings.CutPrefix(key, leaseLabelPrefix)
+		if !ok || keep[nodeKey] {
+			continue
+		}
+
+		sec, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.logger.Warn("无法解析节点租约，跳过",
+				zap.String("upstream_id", upstreamID),
+				zap.String("label", key),
+				zap.String("value", value))
+			continue
+		}
+
+		beat := time.Unix(sec, 0)
+		if now.Sub(beat) > ttl {
+			expired = append(expired, lease{nodeKey: nodeKey, beat: beat})
+		}
+	}
+
+	// 心跳最早的节点最先删除
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].beat.Before(expired[j].beat)
+	})
+
+	remaining := len(upstream.Nodes.List())
+	removed := make([]string, 0, len(expired))
+	skipped := 0
+	for _, l := range expired {
+		if _, exists := upstream.Nodes.Find(l.nodeKey); !exists {
+			if err := c.releaseLease(ctx, upstreamID, l.nodeKey); err != nil {
+				return removed, err
+			}
+			continue
+		}
+
+		if len(removed) >= maxRemovals || remaining <= 1 {
+			skipped++
+			continue
+		}
+
+		if err := c.deleteNode(ctx, upstreamID, l.nodeKey); err != nil {
+			return removed, err
+		}
+		if err := c.releaseLease(ctx, upstreamID, l.nodeKey); err != nil {
+			return removed, err
+		}
+		remaining--
+		removed = append(removed, l.nodeKey)
+
+		c.logger.Warn("节点租约已过期，已从上游删除",
+			zap.String("upstream_id", upstreamID),
+			zap.String("node", l.nodeKey),
+			zap.Time("last_heartbeat", l.beat))
+	}
+
+	if skipped > 0 {
+		c.logger.Warn("达到单次删除上限或只剩最后一个节点，部分过期节点未删除",
+			zap.String("upstream_id", upstreamID),
+			zap.Int("skipped", skipped),
+			zap.Int("max_removals", maxRemovals))
+	}
+
+	return removed, nil
+}
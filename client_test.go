@@ -0,0 +1,415 @@
+package apisix_registration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linabellbiu/apisix-registration/admin"
+	"go.uber.org/zap"
+)
+
+// fakeAdmin 模拟 APISIX 3.x Admin API 的上游和路由资源
+// PUT 整体覆盖资源，PATCH 按合并语义更新：对象按字段合并，null 删除字段，数组整体替换
+type fakeAdmin struct {
+	mu        sync.Mutex
+	resources map[string]map[string]interface{} // 资源路径，例如 upstreams/u1
+	latency   time.Duration                     // 每个请求的最大随机延迟，让并发请求交错
+	status    int                               // 不为0时请求返回该状态码，模拟 Admin API 故障
+	errorMsg  string                            // 故障时返回的 error_msg
+	failKey   string                            // 只有以此开头的资源路径返回故障，为空时所有请求都返回故障
+}
+
+// newFakeAdmin 启动模拟的 Admin API，测试结束时关闭
+func newFakeAdmin(t *testing.T, latency time.Duration) (*fakeAdmin, string) {
+	t.Helper()
+
+	f := &fakeAdmin{resources: map[string]map[string]interface{}{}, latency: latency}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL + "/apisix/admin"
+}
+
+func (f *fakeAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.latency > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(f.latency))))
+	}
+
+	w.Header().Set("Server", "APISIX/3.9.0")
+	w.Header().Set("Content-Type", "application/json")
+
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, "/apisix/admin"), "/")
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.status != 0 && strings.HasPrefix(key, f.failKey) {
+		writeJSON(w, f.status, map[string]interface{}{"error_msg": f.errorMsg})
+		return
+	}
+
+	// 资源集合，只用于识别版本
+	if !strings.Contains(key, "/") {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"total": 0, "list": []interface{}{}})
+		return
+	}
+
+	current, exists := f.resources[key]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"message": "Key not found"})
+			return
+		}
+	case http.MethodPut:
+		var obj map[string]interface{}
+		if err := json.Unmarshal(body, &obj); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error_msg": err.Error()})
+			return
+		}
+		current = obj
+		f.resources[key] = current
+	case http.MethodPatch:
+		if !exists {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"message": "Key not found"})
+			return
+		}
+		var patch map[string]interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error_msg": err.Error()})
+			return
+		}
+		current = mergePatch(current, patch)
+		f.resources[key] = current
+	case http.MethodDelete:
+		delete(f.resources, key)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": "1", "key": "/apisix/" + key})
+		return
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"error_msg": "method not allowed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"key": "/apisix/" + key, "value": current})
+}
+
+// fail 让之后资源路径以 key 开头的请求都返回 status，key 为空时对所有请求生效，status 为0时恢复正常
+func (f *fakeAdmin) fail(key string, status int, errorMsg string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failKey = key
+	f.status = status
+	f.errorMsg = errorMsg
+}
+
+// mergePatch 按 APISIX PATCH 的合并语义把 patch 合并进 dst
+func mergePatch(dst, patch map[string]interface{}) map[string]interface{} {
+	for k, v := range patch {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		pm, ok := v.(map[string]interface{})
+		if dm, exists := dst[k].(map[string]interface{}); ok && exists {
+			dst[k] = mergePatch(dm, pm)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// writeJSON 返回 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// upstreamNodes 读取模拟 Admin API 中上游的节点
+func (f *fakeAdmin) upstreamNodes(t *testing.T, id string) *admin.Nodes {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	upstream, ok := f.resources["upstreams/"+id]
+	if !ok {
+		t.Fatalf("上游 %s 不存在", id)
+	}
+	raw, _ := json.Marshal(upstream["nodes"])
+	var nodes admin.Nodes
+	if err := json.Unmarshal(raw, &nodes); err != nil {
+		t.Fatalf("解析节点失败: %v", err)
+	}
+	return &nodes
+}
+
+// newTestService 创建指向模拟 Admin API 的服务
+func newTestService(t *testing.T, adminAPI string, cfg Config) *Service {
+	t.Helper()
+
+	cfg.Enabled = true
+	cfg.AdminApi = adminAPI
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("创建服务失败: %v", err)
+	}
+	s.logger = zap.NewNop()
+	s.apiClient.logger = s.logger
+	return s
+}
+
+// registerReplicas 并发注册 n 个共用同一个上游的副本
+func registerReplicas(t *testing.T, adminAPI string, n int, base Config) []*Service {
+	t.Helper()
+
+	services := make([]*Service, n)
+	for i := range services {
+		cfg := base
+		cfg.Host = fmt.Sprintf("10.0.0.%d", i+1)
+		services[i] = newTestService(t, adminAPI, cfg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i, s := range services {
+		wg.Add(1)
+		go func(i int, s *Service) {
+			defer wg.Done()
+			errs[i] = s.RegisterContext(ctx)
+		}(i, s)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("副本 %d 注册失败: %v", i+1, err)
+		}
+	}
+	return services
+}
+
+func TestConcurrentRegisterKeepsAllNodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		array bool
+	}{
+		{
+			name: "hash",
+			cfg:  Config{Name: "svc", Port: 8080, Upstream: Upstream{Id: "shared"}},
+		},
+		{
+			name:  "array",
+			cfg:   Config{Name: "svc", Port: 8080, Priority: 1, Upstream: Upstream{Id: "shared"}},
+			array: true,
+		},
+	}
+
+	const replicas = 6
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, adminAPI := newFakeAdmin(t, 20*time.Millisecond)
+			registerReplicas(t, adminAPI, replicas, tt.cfg)
+
+			nodes := fake.upstreamNodes(t, "shared")
+			if nodes.Array != tt.array {
+				t.Errorf("节点格式 array=%v，期望 %v", nodes.Array, tt.array)
+			}
+			if got := len(nodes.List()); got != replicas {
+				t.Fatalf("上游中有 %d 个节点，期望 %d: %+v", got, replicas, nodes.List())
+			}
+			for i := 1; i <= replicas; i++ {
+				key := fmt.Sprintf("10.0.0.%d:8080", i)
+				if _, ok := nodes.Find(key); !ok {
+					t.Errorf("缺少节点 %s", key)
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentDeregisterArrayNodes(t *testing.T) {
+	const replicas = 6
+	fake, adminAPI := newFakeAdmin(t, 20*time.Millisecond)
+	services := registerReplicas(t, adminAPI, replicas, Config{
+		Name:     "svc",
+		Port:     8080,
+		Priority: 1,
+		Upstream: Upstream{Id: "shared"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 一半副本同时注销，另一半同时修改权重，整体写回节点列表时不能互相覆盖
+	var wg sync.WaitGroup
+	for i, s := range services {
+		wg.Add(1)
+		go func(i int, s *Service) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				err = s.DeregisterContext(ctx)
+			} else {
+				_, err = s.apiClient.setNodeWeight(ctx, "shared", s.node(s.host).Key(), 5)
+			}
+			if err != nil {
+				t.Errorf("副本 %d 操作失败: %v", i+1, err)
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	nodes := fake.upstreamNodes(t, "shared")
+	if !nodes.Array {
+		t.Errorf("节点格式变成了哈希格式")
+	}
+	for i := 1; i <= replicas; i++ {
+		key := fmt.Sprintf("10.0.0.%d:8080", i)
+		node, ok := nodes.Find(key)
+		switch {
+		case i%2 == 1 && ok:
+			t.Errorf("已注销的节点 %s 仍在上游中", key)
+		case i%2 == 0 && !ok:
+			t.Errorf("缺少节点 %s", key)
+		case i%2 == 0 && node.Weight != 5:
+			t.Errorf("节点 %s 权重为 %d，期望 5", key, node.Weight)
+		}
+	}
+}
+
+func TestReapStaleNodes(t *testing.T) {
+	const ttl = time.Minute
+
+	tests := []struct {
+		name        string
+		nodes       []string
+		leases      map[string]time.Duration // 节点到上次心跳距今的时间
+		keep        map[string]bool
+		maxRemovals int
+		wantRemoved []string
+		wantNodes   []string
+		wantLeases  []string
+	}{
+		{
+			name:  "单次最多删除 maxRemovals 个，心跳最早的先删除",
+			nodes: []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80", "10.0.0.4:80", "10.0.0.5:80"},
+			leases: map[string]time.Duration{
+				"10.0.0.1:80": 5 * time.Minute,
+				"10.0.0.2:80": 4 * time.Minute,
+				"10.0.0.3:80": 3 * time.Minute,
+				"10.0.0.4:80": 0,
+			},
+			maxRemovals: 2,
+			wantRemoved: []string{"10.0.0.1:80", "10.0.0.2:80"},
+			wantNodes:   []string{"10.0.0.3:80", "10.0.0.4:80", "10.0.0.5:80"},
+			wantLeases:  []string{"10.0.0.3:80", "10.0.0.4:80"},
+		},
+		{
+			name:        "不删除最后一个节点",
+			nodes:       []string{"10.0.0.1:80", "10.0.0.2:80"},
+			leases:      map[string]time.Duration{"10.0.0.1:80": 5 * time.Minute, "10.0.0.2:80": 4 * time.Minute},
+			maxRemovals: 5,
+			wantRemoved: []string{"10.0.0.1:80"},
+			wantNodes:   []string{"10.0.0.2:80"},
+			wantLeases:  []string{"10.0.0.2:80"},
+		},
+		{
+			name:        "跳过 keep 中的节点",
+			nodes:       []string{"10.0.0.1:80", "10.0.0.2:80"},
+			leases:      map[string]time.Duration{"10.0.0.1:80": 5 * time.Minute},
+			keep:        map[string]bool{"10.0.0.1:80": true},
+			maxRemovals: 5,
+			wantRemoved: []string{},
+			wantNodes:   []string{"10.0.0.1:80", "10.0.0.2:80"},
+			wantLeases:  []string{"10.0.0.1:80"},
+		},
+		{
+			name:  "清理节点已不存在的租约，不计入删除数量",
+			nodes: []string{"10.0.0.1:80", "10.0.0.2:80"},
+			leases: map[string]time.Duration{
+				"10.0.0.9:80": 10 * time.Minute,
+				"10.0.0.1:80": 5 * time.Minute,
+			},
+			maxRemovals: 1,
+			wantRemoved: []string{"10.0.0.1:80"},
+			wantNodes:   []string{"10.0.0.2:80"},
+			wantLeases:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, adminAPI := newFakeAdmin(t, 0)
+
+			nodes := map[string]interface{}{}
+			for _, key := range tt.nodes {
+				nodes[key] = 1
+			}
+			labels := map[string]interface{}{"team": "infra"}
+			for key, age := range tt.leases {
+				labels[leaseLabelPrefix+key] = strconv.FormatInt(time.Now().Add(-age).Unix(), 10)
+			}
+			fake.resources["upstreams/u1"] = map[string]interface{}{
+				"id":     "u1",
+				"type":   "roundrobin",
+				"nodes":  nodes,
+				"labels": labels,
+			}
+
+			client := newAPIClient(admin.New(adminAPI, ""), zap.NewNop())
+			removed, err := client.reapStaleNodes(context.Background(), "u1", ttl, tt.maxRemovals, tt.keep)
+			if err != nil {
+				t.Fatalf("清理过期节点失败: %v", err)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("删除了 %v，期望 %v", removed, tt.wantRemoved)
+			}
+
+			gotNodes := make([]string, 0, len(tt.nodes))
+			for _, n := range fake.upstreamNodes(t, "u1").List() {
+				gotNodes = append(gotNodes, n.Key())
+			}
+			sort.Strings(gotNodes)
+			if !reflect.DeepEqual(gotNodes, tt.wantNodes) {
+				t.Errorf("剩余节点 %v，期望 %v", gotNodes, tt.wantNodes)
+			}
+
+			fake.mu.Lock()
+			gotLabels := fake.resources["upstreams/u1"]["labels"].(map[string]interface{})
+			gotLeases := []string{}
+			for key := range gotLabels {
+				if nodeKey, ok := strings.CutPrefix(key, leaseLabelPrefix); ok {
+					gotLeases = append(gotLeases, nodeKey)
+				}
+			}
+			_, hasTeam := gotLabels["team"]
+			fake.mu.Unlock()
+
+			sort.Strings(gotLeases)
+			if !reflect.DeepEqual(gotLeases, tt.wantLeases) {
+				t.Errorf("剩余租约 %v，期望 %v", gotLeases, tt.wantLeases)
+			}
+			if !hasTeam {
+				t.Error("清理租约时删除了其他标签")
+			}
+		})
+	}
+}
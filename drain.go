@@ -0,0 +1,87 @@
+package apisix_registration
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 排空相关的默认参数
+const (
+	// defaultDrainReserve 排空等待结束后至少为删除节点保留的时间
+	defaultDrainReserve = time.Second
+
+	// defaultDrainPollInterval 等待本地请求数归零时的检查间隔
+	defaultDrainPollInterval = 100 * time.Millisecond
+)
+
+// drain 把节点权重改为0，等待 drainPeriod 和本地请求数归零后返回，调用方需持有 s.mu
+//
+// 权重为0后 APISIX 不再把新请求转发到本实例，但仍保持已建立的连接，等待期间这些请求可以正常完成。
+// 排空只是尽力而为：修改权重失败时记录日志后直接删除节点；ctx 带有截止时间时，等待会提前结束，
+// 为删除节点保留 defaultDrainReserve，避免整个注销因超时而失败。
+func (s *Service) drain(ctx context.Context, nodeKeys []string) {
+	if s.drainPeriod <= 0 && s.active == nil {
+		return
+	}
+
+	drained := false
+	for _, nodeKey := range nodeKeys {
+		ok, err := s.apiClient.setNodeWeight(ctx, s.upstreamID, nodeKey, 0)
+		if err != nil {
+			s.logger.Warn("排空节点失败，将直接删除节点", zap.String("node", nodeKey), zap.Error(err))
+			continue
+		}
+		drained = drained || ok
+	}
+	if !drained {
+		return
+	}
+
+	waitCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) > defaultDrainReserve {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithDeadline(ctx, deadline.Add(-defaultDrainReserve))
+		defer cancel()
+	}
+
+	start := time.Now()
+	s.logger.Info("节点权重已改为0，开始排空",
+		zap.String("upstream_id", s.upstreamID),
+		zap.Strings("nodes", nodeKeys),
+		zap.Duration("drain_period", s.drainPeriod))
+
+	if err := sleepContext(waitCtx, s.drainPeriod); err != nil {
+		s.logger.Warn("排空等待被提前结束", zap.Duration("elapsed", time.Since(start)))
+		return
+	}
+
+	if err := s.waitIdle(waitCtx); err != nil {
+		s.logger.Warn("等待本地请求结束超时",
+			zap.Int64("active_requests", s.active()),
+			zap.Duration("elapsed", time.Since(start)))
+		return
+	}
+
+	s.logger.Info("节点排空完成", zap.Duration("elapsed", time.Since(start)))
+}
+
+// waitIdle 等待本地服务正在处理的请求数归零，未设置 OptionsWithActiveRequests 时立即返回
+func (s *Service) waitIdle(ctx context.Context) error {
+	if s.active == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(defaultDrainPollInterval)
+	defer ticker.Stop()
+
+	for s.active() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
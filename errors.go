@@ -3,6 +3,8 @@ package apisix_registration
 import (
 	"errors"
 	"time"
+
+	"github.com/linabellbiu/apisix-registration/admin"
 )
 
 // 基本常量和默认配置
@@ -19,6 +21,9 @@ var (
 	// ErrEmptyHost 主机名不能为空
 	ErrEmptyHost = errors.New("主机不能为空")
 
+	// ErrResolveHost 未配置主机时自动获取本机地址失败
+	ErrResolveHost = errors.New("获取本机地址失败")
+
 	// ErrInvalidPort 端口号必须大于0
 	ErrInvalidPort = errors.New("端口必须大于0")
 
@@ -40,6 +45,15 @@ var (
 	// ErrDeleteNode 从上游删除节点失败
 	ErrDeleteNode = errors.New("从上游删除节点失败")
 
+	// ErrRenewLease 写入节点租约失败
+	ErrRenewLease = errors.New("写入节点租约失败")
+
+	// ErrReap 清理租约过期的节点失败
+	ErrReap = errors.New("清理过期节点失败")
+
+	// ErrNotReady 注册前等待本实例就绪超时
+	ErrNotReady = errors.New("本实例未就绪")
+
 	// ErrStartHealthCheck 启动健康检查服务失败
 	ErrStartHealthCheck = errors.New("启动健康检查服务失败")
 
@@ -49,3 +63,22 @@ var (
 	// ErrInvalidConfig 配置验证失败
 	ErrInvalidConfig = errors.New("配置验证失败")
 )
+
+// APIError 是 APISIX Admin API 返回非预期状态码时的错误
+// 可以通过 errors.As 从 Service 返回的错误中取出
+type APIError = admin.APIError
+
+// IsNotFound 判断错误是否为资源不存在(404)
+func IsNotFound(err error) bool {
+	return admin.IsNotFound(err)
+}
+
+// IsUnauthorized 判断错误是否为认证失败(401)或无权限(403)，通常是 ApiKey 错误
+func IsUnauthorized(err error) bool {
+	return admin.IsUnauthorized(err)
+}
+
+// IsConflict 判断错误是否为资源冲突(409)，例如删除仍被路由引用的上游
+func IsConflict(err error) bool {
+	return admin.IsConflict(err)
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
-	apisix "github.com/linabellbiu/apisix-registration"
+	"context"
 	"log"
+	"os/signal"
+	"syscall"
+
+	apisix "github.com/linabellbiu/apisix-registration"
 )
 
 func main() {
@@ -30,8 +34,11 @@ func main() {
 
 	log.Println("服务已创建，准备注册到APISIX...")
 
-	// 启动服务并处理优雅关闭
-	if err := service.Start(); err != nil {
-		log.Fatalf("服务启动失败: %v", err)
+	// 收到 SIGINT 或 SIGTERM 后结束 Run，Run 返回时已经完成注销
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := service.Run(ctx); err != nil {
+		log.Fatalf("服务运行失败: %v", err)
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -26,7 +27,6 @@ func main() {
 
 	// 创建go-zero服务器
 	server := rest.MustNewServer(c.RestConf)
-	defer server.Stop()
 
 	// 添加一些示例路由
 	server.AddRoute(rest.Route{
@@ -35,21 +35,7 @@ func main() {
 		Handler: helloHandler,
 	})
 
-	// 2. 创建go-zero健康检查处理器适配器
-	goZeroHandler := &apisix.GoZeroHealthHandler{
-		RegisterRoute: func(path string, handler http.HandlerFunc) error {
-			// 在go-zero中注册健康检查路由
-			server.AddRoute(rest.Route{
-				Method:  http.MethodGet,
-				Path:    path,
-				Handler: handler,
-			})
-			log.Printf("已在go-zero中注册健康检查路由: %s", path)
-			return nil
-		},
-	}
-
-	// 3. 创建APISIX服务配置
+	// 2. 创建APISIX服务配置
 	cfg := apisix.Config{
 		Name: "gozero-service",
 		Host: "192.168.3.71",
@@ -65,15 +51,27 @@ func main() {
 		},
 	}
 
-	// 4. 创建APISIX服务实例
-	service, err := apisix.New(cfg, apisix.OptionsWithHealthHandler(goZeroHandler))
+	// 3. 创建APISIX服务实例
+	service, err := apisix.New(cfg)
 	if err != nil {
 		log.Fatalf("创建APISIX服务实例失败: %v", err)
 	}
 
-	if err := service.Start(); err != nil {
-		log.Fatalf("注册到APISIX失败: %v", err)
-	}
+	// 4. 在 server.Start() 之前同步挂载健康检查路由
+	// go-zero 在 Start 中绑定路由，之后再 AddRoute 会与 Start 并发，健康检查路由可能永远不会生效
+	server.AddRoute(rest.Route{
+		Method:  http.MethodGet,
+		Path:    cfg.HealthCfg.Path,
+		Handler: service.HealthHandler().ServeHTTP,
+	})
+
+	// 健康检查路由已经挂载，Run 不会再向 go-zero 添加路由，可以与 server.Start() 并发运行
+	// go-zero 自己处理退出信号，server.Start() 返回后再结束 Run，Run 会同步完成注销
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- service.Run(ctx)
+	}()
 
 	fmt.Println("启动go-zero服务器...")
 	fmt.Println("服务已完全启动")
@@ -83,6 +81,11 @@ func main() {
 	fmt.Println("\n按Ctrl+C终止服务...")
 
 	server.Start()
+
+	cancel()
+	if err := <-done; err != nil {
+		log.Printf("APISIX注册服务异常退出: %v", err)
+	}
 }
 
 // 示例处理函数
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	apisix "github.com/linabellbiu/apisix-registration"
+)
+
+// 独立运行的过期节点清理，可以配置为 Kubernetes CronJob 等定时任务
+func main() {
+	cfg := apisix.ReaperConfig{}
+	flag.StringVar(&cfg.AdminApi, "admin-api", "", "APISIX Admin API 地址")
+	flag.StringVar(&cfg.ApiKey, "api-key", "", "APISIX Admin API 密钥")
+	flag.StringVar(&cfg.UpstreamId, "upstream", "", "需要清理的上游ID（必填）")
+	flag.IntVar(&cfg.TTL, "ttl", apisix.DefaultLeaseTTL, "租约有效期(秒)，需要与实例的配置一致")
+	flag.IntVar(&cfg.MaxRemovals, "max-removals", apisix.DefaultLeaseMaxRemovals, "单次最多删除的节点数")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	removed, err := apisix.Reap(ctx, cfg)
+	if err != nil {
+		log.Fatalf("清理过期节点失败: %v", err)
+	}
+
+	log.Printf("清理完成，删除了 %d 个节点: %v", len(removed), removed)
+}
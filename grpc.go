@@ -0,0 +1,177 @@
+package apisix_registration
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// defaultGrpcWatchInterval Watch 重新执行检查项的间隔，状态变化时才推送
+const defaultGrpcWatchInterval = time.Second
+
+// isGrpcScheme 判断上游协议是否为 gRPC
+func isGrpcScheme(scheme string) bool {
+	return scheme == SchemeGRPC || scheme == SchemeGRPCS
+}
+
+// grpcServiceURIs 把 gRPC 服务全名转换为 APISIX 路由的匹配路径，例如 user.v1.UserService 转换为 /user.v1.UserService/*
+func grpcServiceURIs(services []string) ([]string, error) {
+	uris := make([]string, 0, len(services))
+	for _, name := range services {
+		name = strings.Trim(strings.TrimSpace(name), "/")
+		if name == "" || strings.ContainsAny(name, "/* ") {
+			return nil, fmt.Errorf("gRPC 服务名不合法 %q", name)
+		}
+		uris = append(uris, "/"+name+"/*")
+	}
+	return uris, nil
+}
+
+// OptionsWithGrpcServer 在 gRPC 服务器上注册 grpc.health.v1.Health 服务，需要 Upstream.Scheme 为 grpc 或 grpcs
+// 服务需要在服务器 Serve 之前注册，go-zero zrpc 等在启动时才提供服务器的框架请使用 Service.RegisterGrpcHealth
+func OptionsWithGrpcServer(server *grpc.Server) Option {
+	return func(config *Config) {
+		config.grpcServer = server
+	}
+}
+
+// RegisterGrpcHealth 在 gRPC 服务器上注册 grpc.health.v1.Health 服务，需要在服务器 Serve 之前调用
+// 健康状态来自 AddChecker 添加的检查项，与 HTTP 健康检查路由使用相同的汇总结果，
+// 服务名为空或为服务器上已注册的服务时返回整体状态，调用 Shutdown 后返回 NOT_SERVING
+func (s *Service) RegisterGrpcHealth(server *grpc.Server) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if server == nil {
+		s.logger.Warn("提供的gRPC服务器为nil，未注册健康检查服务")
+		return
+	}
+	if s.grpcHealth != nil {
+		s.logger.Warn("gRPC健康检查服务已注册，已忽略")
+		return
+	}
+
+	s.grpcHealth = newGrpcHealthServer(s.healthSvc, server)
+	healthpb.RegisterHealthServer(server, s.grpcHealth)
+	s.logger.Info("已注册gRPC健康检查服务", zap.String("service", s.name))
+}
+
+// grpcHealthServer 基于检查项实现 grpc.health.v1.Health 服务
+type grpcHealthServer struct {
+	healthpb.UnimplementedHealthServer
+
+	health   *healthService
+	server   *grpc.Server
+	stopping atomic.Bool // 是否已关闭，关闭后所有服务都返回 NOT_SERVING
+}
+
+// newGrpcHealthServer 创建 gRPC 健康检查服务
+func newGrpcHealthServer(health *healthService, server *grpc.Server) *grpcHealthServer {
+	return &grpcHealthServer{
+		health: health,
+		server: server,
+	}
+}
+
+// Check 执行所有检查项并返回整体状态，服务未注册时返回 NotFound
+func (g *grpcHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !g.known(req.GetService()) {
+		return nil, status.Errorf(codes.NotFound, "未知的服务 %s", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: g.status(ctx)}, nil
+}
+
+// Watch 定期执行检查项，状态变化时推送给客户端，服务未注册时推送 SERVICE_UNKNOWN
+func (g *grpcHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(defaultGrpcWatchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		current := healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		if g.known(req.GetService()) {
+			current = g.status(ctx)
+		}
+		if current != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+				return err
+			}
+			last = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// known 判断服务名是否为空或者已经注册在 gRPC 服务器上
+func (g *grpcHealthServer) known(service string) bool {
+	if service == "" {
+		return true
+	}
+	_, ok := g.server.GetServiceInfo()[service]
+	return ok
+}
+
+// status 汇总所有检查项的结果，关闭后始终返回 NOT_SERVING
+func (g *grpcHealthServer) status(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	if g.stopping.Load() {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	report := g.health.report(ctx)
+	if !report.healthy() {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+// shutdown 之后所有服务都返回 NOT_SERVING，让客户端不再选择本实例
+func (g *grpcHealthServer) shutdown() {
+	g.stopping.Store(true)
+}
+
+// probeGrpcReady 通过 grpc.health.v1 探测一次本实例，非 SERVING 视为未就绪
+// 服务器没有注册健康检查服务时，能够收到 Unimplemented 响应说明端口已经可以处理请求，同样视为就绪
+func probeGrpcReady(ctx context.Context, target string, secure bool) error {
+	creds := insecure.NewCredentials()
+	if secure {
+		// 只探测本实例，证书通常签发给对外域名而不是节点地址，因此不校验证书
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
+	}
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, defaultReadyProbeTimeout)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("健康状态 %s", resp.GetStatus())
+	}
+	return nil
+}
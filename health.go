@@ -3,8 +3,11 @@ package apisix_registration
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
-	"time"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -16,15 +19,66 @@ type HealthHandler interface {
 	RegisterHealthCheck(path string, handler http.HandlerFunc) error
 }
 
-// 默认健康检查响应
-func defaultHealthResponse(serviceName string) []byte {
-	responseJSON := fmt.Sprintf(`{"status":"ok","service":"%s","time":"%s"}`,
-		serviceName,
-		time.Now().Format(time.RFC3339))
-	return []byte(responseJSON)
+// methodHealthHandler 可以按请求方法注册健康检查路由的处理器，例如 gin 这类按方法匹配路由的框架
+// 健康检查路由只注册在 HealthCfg.Method 配置的方法上，其他方法由框架自己处理
+type methodHealthHandler interface {
+	RegisterHealthCheckMethod(method, path string, handler http.HandlerFunc) error
 }
 
-// StandardHealthHandler 标准http.Handler适配器
+// 健康检查支持的请求方法
+const (
+	HealthMethodGet  = http.MethodGet                         // 只响应 GET（默认）
+	HealthMethodHead = http.MethodHead                        // 只响应 HEAD
+	HealthMethodBoth = http.MethodGet + "," + http.MethodHead // 同时响应 GET 和 HEAD
+)
+
+// parseHealthMethods 解析健康检查方法配置，支持 GET、HEAD 以及逗号分隔的组合
+func parseHealthMethods(method string) ([]string, error) {
+	if strings.TrimSpace(method) == "" {
+		return []string{DefaultHealthCheckMethod}, nil
+	}
+
+	var methods []string
+	for _, m := range strings.Split(method, ",") {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m != http.MethodGet && m != http.MethodHead {
+			return nil, fmt.Errorf("%w: 健康检查只支持 GET 和 HEAD 方法 %s", ErrInvalidConfig, method)
+		}
+		if !containsString(methods, m) {
+			methods = append(methods, m)
+		}
+	}
+	return methods, nil
+}
+
+// validateHealthMethod 校验健康检查方法配置并规范化 Method，返回支持的请求方法
+// APISIX 的主动检查固定使用 GET 探测，开启健康检查时方法中必须包含 GET
+func validateHealthMethod(cfg *HealthCheckConfig) ([]string, error) {
+	methods, err := parseHealthMethods(cfg.Method)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Enabled && !containsString(methods, http.MethodGet) {
+		return nil, fmt.Errorf("%w: APISIX 主动检查使用 GET 探测，开启健康检查时方法需要包含 GET %s", ErrInvalidConfig, cfg.Method)
+	}
+	cfg.Method = strings.Join(methods, ",")
+	return methods, nil
+}
+
+// containsString 判断 list 中是否包含 s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// StandardHealthHandler 标准http.Handler适配器，健康检查路由上不支持的请求方法返回 405
+//
+// Deprecated: 注册时包装 Server.Handler，之后替换 Server.Handler 会丢失健康检查路由，
+// 请使用 Service.HealthMiddleware 或 Service.HealthHandler。
 type StandardHealthHandler struct {
 	Server *http.Server
 }
@@ -59,17 +113,24 @@ type GinHealthHandler struct {
 	Engine *gin.Engine
 }
 
-// RegisterHealthCheck 实现HealthHandler接口
+// RegisterHealthCheck 实现HealthHandler接口，在 GET 上注册路由
 func (h *GinHealthHandler) RegisterHealthCheck(path string, handler http.HandlerFunc) error {
+	return h.RegisterHealthCheckMethod(DefaultHealthCheckMethod, path, handler)
+}
+
+// RegisterHealthCheckMethod 按请求方法注册健康检查路由
+// 直接包装库提供的处理函数，与 net/http 共用同一套检查项和响应，
+// 其他方法的请求由 gin 处理，开启 Engine.HandleMethodNotAllowed 后返回 405
+func (h *GinHealthHandler) RegisterHealthCheckMethod(method, path string, handler http.HandlerFunc) error {
 	if h.Engine == nil {
 		return fmt.Errorf("Gin引擎为空")
 	}
+	if handler == nil {
+		return fmt.Errorf("健康检查处理函数为空")
+	}
 
 	// 为Gin添加健康检查路由
-	h.Engine.GET(path, func(c *gin.Context) {
-		resp := defaultHealthResponse(c.GetHeader("X-Service-Name"))
-		c.Data(http.StatusOK, "application/json", resp)
-	})
+	h.Engine.Handle(method, path, gin.WrapF(handler))
 
 	return nil
 }
@@ -79,6 +140,10 @@ type GoZeroHealthHandler struct {
 	// 可以持有go-zero服务器引用
 	// 这里使用通用接口，用户需要实现这个接口
 	RegisterRoute func(path string, handler http.HandlerFunc) error
+
+	// RegisterRouteMethod 按请求方法注册路由，设置后优先于 RegisterRoute，
+	// 健康检查路由只注册在 HealthCfg.Method 配置的方法上，其他方法由 go-zero 返回 405
+	RegisterRouteMethod func(method, path string, handler http.HandlerFunc) error
 }
 
 // RegisterHealthCheck 实现HealthHandler接口
@@ -89,14 +154,40 @@ func (h *GoZeroHealthHandler) RegisterHealthCheck(path string, handler http.Hand
 	return h.RegisterRoute(path, handler)
 }
 
+// RegisterHealthCheckMethod 按请求方法注册健康检查路由，未设置 RegisterRouteMethod 时只在 GET 上调用 RegisterRoute
+func (h *GoZeroHealthHandler) RegisterHealthCheckMethod(method, path string, handler http.HandlerFunc) error {
+	if h.RegisterRouteMethod != nil {
+		return h.RegisterRouteMethod(method, path, handler)
+	}
+	if method != http.MethodGet {
+		return nil
+	}
+	return h.RegisterHealthCheck(path, handler)
+}
+
 // healthService 提供健康检查服务
 type healthService struct {
 	serviceName   string
 	port          int
+	addr          string // 独立健康检查监听地址，为空时监听服务端口
+	external      bool   // 是否已通过 HealthHandler 或 HealthMiddleware 由业务自行挂载
 	server        *http.Server
 	customHandler HealthHandler
 	healthPath    string
+	livePath      string   // 存活检查路由，为空时只在内部服务器上使用默认路由，为 HealthPathDisabled 时关闭
+	readyPath     string   // 就绪检查路由，规则同 livePath
+	methods       []string // 健康检查支持的请求方法
+	mounted       bool     // 是否已向自定义处理器注册路由，重试启动时不再重复注册
 	logger        *zap.Logger
+
+	mu       sync.RWMutex
+	checkers []*checker
+}
+
+// healthRoute 健康检查路由及其处理函数
+type healthRoute struct {
+	path    string
+	handler http.HandlerFunc
 }
 
 // newHealthService 创建健康检查服务
@@ -105,6 +196,7 @@ func newHealthService(serviceName string, port int, logger *zap.Logger) *healthS
 		serviceName: serviceName,
 		port:        port,
 		healthPath:  "/health", // 默认健康检查路径
+		methods:     []string{DefaultHealthCheckMethod},
 		logger:      logger,
 	}
 }
@@ -132,34 +224,306 @@ func (h *healthService) setCustomServer(server *http.Server, healthPath string)
 	}
 }
 
-// 健康检查处理函数
-func (h *healthService) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(defaultHealthResponse(h.serviceName))
+// addChecker 添加健康检查项，名称相同时替换原有的检查项
+func (h *healthService) addChecker(name string, check Checker, kind CheckKind, opts ...CheckerOption) {
+	c := newChecker(name, check, kind, opts...)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := range h.checkers {
+		if h.checkers[i].name == name {
+			h.checkers[i] = c
+			return
+		}
+	}
+	h.checkers = append(h.checkers, c)
+}
+
+// hasCheckers 判断是否添加了健康检查项
+func (h *healthService) hasCheckers() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.checkers) > 0
+}
+
+// report 并发执行指定类型的检查项并汇总结果，未指定类型时执行所有检查项
+func (h *healthService) report(ctx context.Context, kinds ...CheckKind) healthReport {
+	h.mu.RLock()
+	checkers := make([]*checker, 0, len(h.checkers))
+	for _, c := range h.checkers {
+		if len(kinds) == 0 || containsKind(kinds, c.kind) {
+			checkers = append(checkers, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	results := make([]CheckResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func(i int, c *checker) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	return newHealthReport(h.serviceName, results)
+}
+
+// containsKind 判断 kinds 中是否包含 kind
+func containsKind(kinds []CheckKind, kind CheckKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// reportHandler 返回执行指定类型检查项的处理函数
+func (h *healthService) reportHandler(kinds ...CheckKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, r, h.report(r.Context(), kinds...))
+	}
+}
+
+// allowMethods 只按路径匹配路由时使用，不支持的请求方法返回 405
+// 按方法注册的路由由框架处理其他方法，不需要再检查
+func (h *healthService) allowMethods(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !containsString(h.methods, r.Method) {
+			w.Header().Set("Allow", strings.Join(h.methods, ", "))
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// routes 返回需要注册的健康检查路由
+// 存活检查只执行 Liveness 检查项，就绪检查和健康检查路由执行所有检查项
+// internal 为 false 时路由挂载在业务自己的路由器上，只有显式配置的存活和就绪检查路由才会挂载，避免与业务已有的路由冲突
+func (h *healthService) routes(internal bool) []healthRoute {
+	routes := []healthRoute{{path: h.healthPath, handler: h.reportHandler()}}
+	if path := probePath(h.livePath, DefaultLivenessRoute, internal); path != "" {
+		routes = append(routes, healthRoute{path: path, handler: h.reportHandler(Liveness)})
+	}
+	if path := probePath(h.readyPath, DefaultReadinessRoute, internal); path != "" {
+		routes = append(routes, healthRoute{path: path, handler: h.reportHandler()})
+	}
+	return routes
+}
+
+// probePath 返回存活或就绪检查实际使用的路由，返回空表示不挂载
+func probePath(path, defaultPath string, internal bool) string {
+	switch {
+	case path == HealthPathDisabled:
+		return ""
+	case path == "" && internal:
+		return defaultPath
+	default:
+		return path
+	}
+}
+
+// routePaths 返回路由的路径，用于日志
+func routePaths(routes []healthRoute) []string {
+	paths := make([]string, 0, len(routes))
+	for _, route := range routes {
+		paths = append(paths, route.path)
+	}
+	return paths
+}
+
+// validateHealthListener 校验独立健康检查监听地址，Addr 中的端口会同步到 Port
+// 开启健康检查时 APISIX 会探测 节点地址:Port，Addr 绑定在回环地址或节点以外的地址上时 APISIX 无法访问
+func validateHealthListener(cfg *HealthCheckConfig, host, host6 string, logger *zap.Logger) error {
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return fmt.Errorf("%w: 健康检查端口不合法 %d", ErrInvalidConfig, cfg.Port)
+	}
+	if cfg.Addr == "" {
+		return nil
+	}
+
+	listenHost, portStr, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("%w: 健康检查监听地址不合法 %s: %w", ErrInvalidConfig, cfg.Addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("%w: 健康检查监听地址需要指定端口 %s", ErrInvalidConfig, cfg.Addr)
+	}
+	if cfg.Port != 0 && cfg.Port != port {
+		return fmt.Errorf("%w: 健康检查端口 %d 与监听地址 %s 不一致", ErrInvalidConfig, cfg.Port, cfg.Addr)
+	}
+	cfg.Port = port
+
+	if cfg.Enabled {
+		return checkListenHost(listenHost, host, host6, logger)
+	}
+	return nil
+}
+
+// checkListenHost 检查 APISIX 能否通过节点地址访问健康检查监听地址
+// 回环地址和节点以外的 IP 直接返回错误，无法判断的主机名只记录警告
+func checkListenHost(listenHost, host, host6 string, logger *zap.Logger) error {
+	if listenHost == "" || listenHost == host || listenHost == host6 {
+		return nil
+	}
+
+	ip := net.ParseIP(listenHost)
+	switch {
+	case ip == nil && strings.EqualFold(listenHost, "localhost"):
+		return fmt.Errorf("%w: 健康检查监听地址 %s 是回环地址，APISIX 无法访问", ErrInvalidConfig, listenHost)
+	case ip == nil:
+		logger.Warn("健康检查监听地址与节点地址不同，APISIX 可能无法访问",
+			zap.String("listen_host", listenHost),
+			zap.String("host", host),
+			zap.String("host6", host6))
+		return nil
+	case ip.IsUnspecified():
+		return nil
+	case ip.IsLoopback():
+		return fmt.Errorf("%w: 健康检查监听地址 %s 是回环地址，APISIX 无法访问", ErrInvalidConfig, listenHost)
+	case ip.Equal(net.ParseIP(host)) || ip.Equal(net.ParseIP(host6)):
+		return nil
+	default:
+		return fmt.Errorf("%w: 健康检查监听地址 %s 不是节点地址 %s，APISIX 无法访问", ErrInvalidConfig, listenHost, host)
+	}
+}
+
+// setListener 使用独立的监听地址提供健康检查，addr 为空时只指定端口
+func (h *healthService) setListener(addr string, port int) {
+	switch {
+	case addr != "":
+		h.addr = addr
+	case port > 0:
+		h.addr = fmt.Sprintf(":%d", port)
+	}
+}
+
+// dedicated 判断是否使用独立的健康检查监听地址
+func (h *healthService) dedicated() bool {
+	return h.addr != ""
+}
+
+// listenAddr 返回内部健康检查服务器的监听地址
+func (h *healthService) listenAddr() string {
+	if h.dedicated() {
+		return h.addr
+	}
+	return fmt.Sprintf(":%d", h.port)
+}
+
+// probeAddr 返回从本机访问健康检查服务的地址，监听所有地址时使用 host
+func (h *healthService) probeAddr(host string) string {
+	listenHost, port, err := net.SplitHostPort(h.listenAddr())
+	if err != nil {
+		return net.JoinHostPort(host, strconv.Itoa(h.port))
+	}
+	if ip := net.ParseIP(listenHost); listenHost != "" && (ip == nil || !ip.IsUnspecified()) {
+		host = listenHost
+	}
+	return net.JoinHostPort(host, port)
 }
 
 // start 启动健康检查服务
 func (h *healthService) start() error {
+	// 配置了独立监听地址时，健康检查只在该地址上提供，不占用服务端口
+	if h.dedicated() {
+		if h.customHandler != nil {
+			h.logger.Info("已配置独立的健康检查监听地址，不再向自定义服务器添加健康检查路由",
+				zap.String("addr", h.addr))
+		}
+		return h.startNewServer()
+	}
+
 	// 如果有自定义处理器，使用它来注册健康检查
 	if h.customHandler != nil {
 		return h.registerHealthCheck()
 	}
 
+	// 业务已经自行挂载了健康检查，不需要再启动服务器
+	if h.isExternal() {
+		h.logger.Info("健康检查已由业务自行挂载，不启动内部服务器",
+			zap.String("health_path", h.healthPath))
+		return nil
+	}
+
 	// 否则创建新的服务器
 	return h.startNewServer()
 }
 
+// markExternal 标记健康检查由业务自行挂载
+func (h *healthService) markExternal() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.external = true
+}
+
+// isExternal 判断健康检查是否由业务自行挂载
+func (h *healthService) isExternal() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.external
+}
+
+// middleware 拦截健康检查路由，其他请求交给 next，健康检查路由上不支持的方法返回 405
+// 每次请求时匹配路由，之后修改健康检查路径也能生效
+func (h *healthService) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range h.routes(false) {
+			if r.URL.Path == route.path {
+				h.allowMethods(route.handler)(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// methodHandler 返回可以按请求方法注册路由的处理器，go-zero 适配器未设置 RegisterRouteMethod 时只能按路径注册
+func methodHandler(handler HealthHandler) (methodHealthHandler, bool) {
+	if gz, ok := handler.(*GoZeroHealthHandler); ok && gz.RegisterRouteMethod == nil {
+		return nil, false
+	}
+	mh, ok := handler.(methodHealthHandler)
+	return mh, ok
+}
+
 // registerHealthCheck 向自定义处理器注册健康检查
 func (h *healthService) registerHealthCheck() error {
-	err := h.customHandler.RegisterHealthCheck(h.healthPath, h.healthCheckHandler)
-	if err != nil {
-		return fmt.Errorf("注册健康检查路由失败: %w", err)
+	// 路由注册到业务的路由器上无法撤销，启动失败后重试时再次注册会与已有的路由冲突
+	if h.mounted {
+		return nil
 	}
 
+	routes := h.routes(false)
+	for _, route := range routes {
+		var err error
+		if mh, ok := methodHandler(h.customHandler); ok {
+			for _, method := range h.methods {
+				if err = mh.RegisterHealthCheckMethod(method, route.path, route.handler); err != nil {
+					break
+				}
+			}
+		} else {
+			err = h.customHandler.RegisterHealthCheck(route.path, h.allowMethods(route.handler))
+		}
+		if err != nil {
+			return fmt.Errorf("注册健康检查路由 %s 失败: %w", route.path, err)
+		}
+	}
+
+	h.mounted = true
 	h.logger.Info("已向自定义服务器添加健康检查路由",
 		zap.String("service", h.serviceName),
-		zap.String("health_path", h.healthPath))
+		zap.Strings("paths", routePaths(routes)))
 
 	return nil
 }
@@ -167,32 +531,39 @@ func (h *healthService) registerHealthCheck() error {
 // startNewServer 启动新的健康检查服务器
 func (h *healthService) startNewServer() error {
 	router := gin.Default()
+	router.HandleMethodNotAllowed = true
 
-	// 添加健康检查路由
-	router.GET(h.healthPath, func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "ok",
-			"service": h.serviceName,
-			"time":    time.Now().Format(time.RFC3339),
-		})
-	})
+	// 添加健康检查路由，只注册配置的方法，其他方法由 gin 返回 405
+	routes := h.routes(true)
+	for _, route := range routes {
+		for _, method := range h.methods {
+			router.Handle(method, route.path, gin.WrapF(route.handler))
+		}
+	}
+
+	// 同步监听，端口被占用等错误直接返回给调用方
+	addr := h.listenAddr()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("监听健康检查地址 %s 失败: %w", addr, err)
+	}
 
 	h.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", h.port),
+		Addr:    addr,
 		Handler: router,
 	}
 
 	// 启动HTTP服务器
 	go func() {
-		if err := h.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			h.logger.Error("健康检查服务启动失败", zap.Error(err))
+		if err := h.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			h.logger.Error("健康检查服务异常退出", zap.Error(err))
 		}
 	}()
 
 	h.logger.Info("健康检查服务已启动",
 		zap.String("service", h.serviceName),
-		zap.Int("port", h.port),
-		zap.String("health_path", h.healthPath),
+		zap.String("addr", ln.Addr().String()),
+		zap.Strings("paths", routePaths(routes)),
 	)
 
 	return nil
@@ -200,12 +571,7 @@ func (h *healthService) startNewServer() error {
 
 // shutdown 关闭健康检查服务
 func (h *healthService) shutdown(ctx context.Context) error {
-	// 如果使用的是自定义处理器，不需要关闭（由外部管理）
-	if h.customHandler != nil {
-		return nil
-	}
-
-	// 关闭内部创建的服务器
+	// 使用自定义处理器时没有内部服务器，不需要关闭（由外部管理）
 	if h.server == nil {
 		return nil
 	}
@@ -216,3 +582,23 @@ func (h *healthService) shutdown(ctx context.Context) error {
 
 	return nil
 }
+
+// HealthHandler 返回执行所有检查项的健康检查 http.Handler，可以挂载到任意路由，例如
+//
+//	mux.Handle("GET /health", service.HealthHandler())
+//
+// 调用后 Start 和 Run 不再启动内部的健康检查服务器（配置了独立监听地址时除外）
+func (s *Service) HealthHandler() http.Handler {
+	s.healthSvc.markExternal()
+	return s.healthSvc.allowMethods(s.healthSvc.reportHandler())
+}
+
+// HealthMiddleware 返回拦截健康检查路由以及显式配置的存活和就绪检查路由的中间件，其他请求交给 next，例如
+//
+//	server.Handler = service.HealthMiddleware(mux)
+//
+// 健康检查路由上不支持的请求方法返回 405，调用后 Start 和 Run 不再启动内部的健康检查服务器（配置了独立监听地址时除外）
+func (s *Service) HealthMiddleware(next http.Handler) http.Handler {
+	s.healthSvc.markExternal()
+	return s.healthSvc.middleware(next)
+}
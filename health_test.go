@@ -0,0 +1,128 @@
+package apisix_registration
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		livePath  string
+		readyPath string
+		internal  bool
+		want      []string
+	}{
+		{name: "内部服务器使用默认路由", internal: true, want: []string{"/health", "/livez", "/readyz"}},
+		{name: "业务路由器不挂载未配置的路由", want: []string{"/health"}},
+		{name: "业务路由器挂载显式配置的路由", livePath: "/live", readyPath: "/ready", want: []string{"/health", "/live", "/ready"}},
+		{name: "关闭存活检查", livePath: HealthPathDisabled, internal: true, want: []string{"/health", "/readyz"}},
+		{name: "关闭就绪检查", livePath: "/live", readyPath: HealthPathDisabled, want: []string{"/health", "/live"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHealthService("svc", 8080, zap.NewNop())
+			h.livePath = tt.livePath
+			h.readyPath = tt.readyPath
+
+			if got := routePaths(h.routes(tt.internal)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routes(%v) = %v，期望 %v", tt.internal, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateHealthListenerHost(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{addr: ":9091"},
+		{addr: "0.0.0.0:9091"},
+		{addr: "[::]:9091"},
+		{addr: "10.0.0.1:9091"},
+		{addr: "[fd00::1]:9091"},
+		{addr: "health.internal:9091"},
+		{addr: "127.0.0.1:9091", wantErr: true},
+		{addr: "[::1]:9091", wantErr: true},
+		{addr: "localhost:9091", wantErr: true},
+		{addr: "10.0.0.2:9091", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			cfg := HealthCheckConfig{Enabled: true, Addr: tt.addr}
+			err := validateHealthListener(&cfg, "10.0.0.1", "fd00::1", zap.NewNop())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateHealthListener(%s) 错误 = %v，期望出错 %v", tt.addr, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("错误应该包装 ErrInvalidConfig: %v", err)
+			}
+		})
+	}
+
+	// 未开启健康检查时 APISIX 不会探测，不校验监听主机
+	cfg := HealthCheckConfig{Addr: "127.0.0.1:9091"}
+	if err := validateHealthListener(&cfg, "10.0.0.1", "", zap.NewNop()); err != nil {
+		t.Errorf("未开启健康检查时不应校验监听主机: %v", err)
+	}
+}
+
+func TestGinHealthRouteMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.HandleMethodNotAllowed = true
+	// 业务路由器上已有同路径的其他方法，注册健康检查路由时不能冲突
+	engine.POST("/health", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	h := newHealthService("svc", 8080, zap.NewNop())
+	h.methods = []string{http.MethodGet, http.MethodHead}
+	h.setCustomHandler(&GinHealthHandler{Engine: engine}, "/health")
+	if err := h.registerHealthCheck(); err != nil {
+		t.Fatalf("注册健康检查路由失败: %v", err)
+	}
+
+	if got := len(engine.Routes()); got != 3 {
+		t.Errorf("注册了 %d 个路由，期望 3: %+v", got, engine.Routes())
+	}
+
+	tests := []struct {
+		method string
+		want   int
+	}{
+		{method: http.MethodGet, want: http.StatusOK},
+		{method: http.MethodHead, want: http.StatusOK},
+		{method: http.MethodPost, want: http.StatusCreated},
+		{method: http.MethodDelete, want: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(tt.method, "/health", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s /health 返回 %d，期望 %d", tt.method, w.Code, tt.want)
+		}
+	}
+}
+
+func TestHealthMiddlewareMethodNotAllowed(t *testing.T) {
+	h := newHealthService("svc", 8080, zap.NewNop())
+	handler := h.middleware(http.NotFoundHandler())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/health", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /health 返回 %d，期望 %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if got := w.Header().Get("Allow"); got != http.MethodGet {
+		t.Errorf("Allow = %q，期望 %q", got, http.MethodGet)
+	}
+}
@@ -0,0 +1,288 @@
+package apisix_registration
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// normalizeHost 校验主机并去掉 IPv6 地址两侧的方括号
+// 主机必须是 IP 地址或符合 RFC 1123 的主机名
+func normalizeHost(host string) (string, error) {
+	h := strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if ip := net.ParseIP(h); ip != nil {
+		return ip.String(), nil
+	}
+
+	if h != host {
+		return "", fmt.Errorf("%w: 方括号中不是有效的 IPv6 地址 %s", ErrInvalidConfig, host)
+	}
+
+	if !isHostname(host) {
+		return "", fmt.Errorf("%w: 主机既不是 IP 地址也不是有效的主机名 %s", ErrInvalidConfig, host)
+	}
+
+	return host, nil
+}
+
+// isHostname 判断是否为符合 RFC 1123 的主机名
+func isHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// isIPv6 判断是否为 IPv6 地址
+func isIPv6(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
+}
+
+// sanitizeID 把主机中 APISIX 资源ID不允许的字符替换掉，ID 只能包含字母、数字、-、_ 和 .
+func sanitizeID(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '-'
+		}
+	}, id)
+}
+
+// 本实例地址的解析策略
+const (
+	HostStrategyAuto      = ""          // 依次尝试 env、interface、cidr、outbound
+	HostStrategyEnv       = "env"       // 从环境变量读取，例如 Kubernetes 注入的 POD_IP
+	HostStrategyInterface = "interface" // 使用指定网卡的地址
+	HostStrategyCIDR      = "cidr"      // 使用落在指定网段内的地址
+	HostStrategyOutbound  = "outbound"  // 使用访问 APISIX Admin API 时的出口地址
+)
+
+// defaultHostEnvVars 默认读取的环境变量
+var defaultHostEnvVars = []string{"POD_IP", "HOST_IP"}
+
+// HostResolver 自定义获取本实例地址的函数
+type HostResolver func() (string, error)
+
+// HostResolveConfig 未配置 Host 时自动获取本实例地址的配置
+type HostResolveConfig struct {
+	Strategy       string   `json:",optional"` // 解析策略: env、interface、cidr、outbound，为空时依次尝试
+	EnvVars        []string `json:",optional"` // 读取地址的环境变量，默认 POD_IP、HOST_IP
+	Interface      string   `json:",optional"` // 网卡名称，例如 eth0
+	PreferredCIDRs []string `json:",optional"` // 优先选择的网段，按顺序匹配，例如 10.0.0.0/8
+	OutboundTarget string   `json:",optional"` // outbound 策略探测的目标地址 host:port，默认为 Admin API 地址
+	AllowLoopback  bool     `json:",optional"` // 是否允许回环地址和链路本地地址
+}
+
+// resolveHost 按策略获取本实例地址，custom 不为空时优先使用
+func resolveHost(cfg HostResolveConfig, adminAPI string, custom HostResolver) (string, error) {
+	if custom != nil {
+		host, err := custom()
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrResolveHost, err)
+		}
+		return checkResolvedHost(host, cfg.AllowLoopback)
+	}
+
+	strategies := map[string]func() (string, error){
+		HostStrategyEnv:       func() (string, error) { return hostFromEnv(cfg.EnvVars) },
+		HostStrategyInterface: func() (string, error) { return hostFromInterface(cfg.Interface, cfg.AllowLoopback) },
+		HostStrategyCIDR:      func() (string, error) { return hostFromCIDRs(cfg.PreferredCIDRs, cfg.AllowLoopback) },
+		HostStrategyOutbound:  func() (string, error) { return hostFromOutbound(cfg.OutboundTarget, adminAPI) },
+	}
+
+	var order []string
+	switch cfg.Strategy {
+	case HostStrategyAuto:
+		order = append(order, HostStrategyEnv)
+		if cfg.Interface != "" {
+			order = append(order, HostStrategyInterface)
+		}
+		if len(cfg.PreferredCIDRs) > 0 {
+			order = append(order, HostStrategyCIDR)
+		}
+		order = append(order, HostStrategyOutbound)
+	case HostStrategyEnv, HostStrategyInterface, HostStrategyCIDR, HostStrategyOutbound:
+		order = []string{cfg.Strategy}
+	default:
+		return "", fmt.Errorf("%w: 不支持的地址解析策略 %s", ErrInvalidConfig, cfg.Strategy)
+	}
+
+	var reasons []string
+	for _, name := range order {
+		host, err := strategies[name]()
+		if err == nil {
+			host, err = checkResolvedHost(host, cfg.AllowLoopback)
+		}
+		if err == nil {
+			return host, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %v", name, err))
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrResolveHost, strings.Join(reasons, "; "))
+}
+
+// checkResolvedHost 校验解析到的地址，除非显式允许，否则拒绝回环地址和链路本地地址
+func checkResolvedHost(host string, allowLoopback bool) (string, error) {
+	host, err := normalizeHost(host)
+	if err != nil {
+		return "", err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host, nil
+	}
+	if ip.IsUnspecified() {
+		return "", fmt.Errorf("不能使用未指定地址 %s", host)
+	}
+	if !usableIP(ip, allowLoopback) {
+		return "", fmt.Errorf("APISIX 无法访问回环或链路本地地址 %s", host)
+	}
+
+	return host, nil
+}
+
+// usableIP 判断地址能否注册为节点
+func usableIP(ip net.IP, allowLoopback bool) bool {
+	if ip.IsUnspecified() {
+		return false
+	}
+	if allowLoopback {
+		return true
+	}
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
+
+// hostFromEnv 返回第一个非空环境变量的值
+func hostFromEnv(envVars []string) (string, error) {
+	if len(envVars) == 0 {
+		envVars = defaultHostEnvVars
+	}
+
+	for _, name := range envVars {
+		if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+			return value, nil
+		}
+	}
+
+	return "", fmt.Errorf("环境变量 %s 均未设置", strings.Join(envVars, "、"))
+}
+
+// hostFromInterface 返回指定网卡上第一个可用地址，优先 IPv4
+func hostFromInterface(name string, allowLoopback bool) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("未指定网卡名称")
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+
+	if ip := pickIP(addrs, allowLoopback, func(net.IP) bool { return true }); ip != nil {
+		return ip.String(), nil
+	}
+
+	return "", fmt.Errorf("网卡 %s 上没有可用地址", name)
+}
+
+// hostFromCIDRs 返回落在指定网段内的地址，按网段顺序优先
+func hostFromCIDRs(cidrs []string, allowLoopback bool) (string, error) {
+	if len(cidrs) == 0 {
+		return "", fmt.Errorf("未指定网段")
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return "", fmt.Errorf("%w: 无效的网段 %s", ErrInvalidConfig, cidr)
+		}
+		if ip := pickIP(addrs, allowLoopback, network.Contains); ip != nil {
+			return ip.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("没有落在网段 %s 内的地址", strings.Join(cidrs, "、"))
+}
+
+// hostFromOutbound 返回访问目标地址时使用的本机出口地址
+// 使用 UDP 建立“连接”只会查询路由表，不会真正发送数据
+func hostFromOutbound(target, adminAPI string) (string, error) {
+	if target == "" {
+		u, err := url.Parse(adminAPI)
+		if err != nil || u.Hostname() == "" {
+			return "", fmt.Errorf("无法从 Admin API 地址获取探测目标: %s", adminAPI)
+		}
+		port := u.Port()
+		if port == "" {
+			port = "80"
+		}
+		target = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return "", fmt.Errorf("无法获取出口地址")
+	}
+
+	return addr.IP.String(), nil
+}
+
+// pickIP 从网卡地址中选出满足条件的地址，优先 IPv4
+func pickIP(addrs []net.Addr, allowLoopback bool, match func(net.IP) bool) net.IP {
+	var v6 net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !usableIP(ipNet.IP, allowLoopback) || !match(ipNet.IP) {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+		if v6 == nil {
+			v6 = ipNet.IP
+		}
+	}
+	return v6
+}
@@ -0,0 +1,154 @@
+package apisix_registration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/linabellbiu/apisix-registration/admin"
+	"go.uber.org/zap"
+)
+
+// 租约默认配置
+const (
+	// DefaultLeaseTTL 默认租约有效期(秒)
+	DefaultLeaseTTL = 30
+
+	// DefaultLeaseMaxRemovals 默认单次清理最多删除的节点数
+	DefaultLeaseMaxRemovals = 1
+
+	// leaseLabelPrefix 节点租约在上游标签中的前缀，完整的键为 lease_{host:port}
+	leaseLabelPrefix = "lease_"
+)
+
+// LeaseConfig 租约配置
+//
+// 开启后每个实例定期把心跳时间写入上游标签，实例被 SIGKILL 或 OOM 杀掉时来不及注销，
+// 租约过期后由清理者把节点从上游删除。清理者可以是开启了 Reap 的任意实例，也可以是独立运行的 Reap 函数。
+type LeaseConfig struct {
+	Enabled     bool `json:",optional"` // 是否开启租约
+	TTL         int  `json:",optional"` // 租约有效期(秒)，默认为 DefaultLeaseTTL，每 TTL/3 续约一次
+	Reap        bool `json:",optional"` // 本实例是否同时清理租约过期的节点
+	MaxRemovals int  `json:",optional"` // 单次清理最多删除的节点数，默认为 DefaultLeaseMaxRemovals
+}
+
+// ReaperConfig 独立运行清理时的配置
+type ReaperConfig struct {
+	AdminApi    string `json:",optional"` // APISIX Admin API 地址
+	ApiKey      string `json:",optional"` // APISIX Admin API 密钥
+	UpstreamId  string // 需要清理的上游ID
+	TTL         int    `json:",optional"` // 租约有效期(秒)，需要与实例的配置一致，默认为 DefaultLeaseTTL
+	MaxRemovals int    `json:",optional"` // 单次清理最多删除的节点数，默认为 DefaultLeaseMaxRemovals
+}
+
+// validateLease 校验租约配置并补全默认值
+func validateLease(lease *LeaseConfig) error {
+	if lease.TTL < 0 || lease.MaxRemovals < 0 {
+		return fmt.Errorf("%w: 租约有效期和单次删除数量不能小于0", ErrInvalidConfig)
+	}
+	if lease.TTL == 0 {
+		lease.TTL = DefaultLeaseTTL
+	}
+	if lease.MaxRemovals == 0 {
+		lease.MaxRemovals = DefaultLeaseMaxRemovals
+	}
+	return nil
+}
+
+// Reap 独立清理一个上游中租约过期的节点，返回被删除的节点
+// 适合以定时任务的方式运行，同一个上游同时只应运行一个清理者
+func Reap(ctx context.Context, cfg ReaperConfig) ([]string, error) {
+	if cfg.AdminApi == "" {
+		cfg.AdminApi = DefaultAdminApi
+	}
+	if cfg.UpstreamId == "" {
+		return nil, fmt.Errorf("%w: 上游ID不能为空", ErrInvalidConfig)
+	}
+
+	lease := LeaseConfig{TTL: cfg.TTL, MaxRemovals: cfg.MaxRemovals}
+	if err := validateLease(&lease); err != nil {
+		return nil, err
+	}
+
+	logger, _ := zap.NewProduction()
+	client := newAPIClient(admin.New(cfg.AdminApi, cfg.ApiKey), logger)
+
+	removed, err := client.reapStaleNodes(ctx, cfg.UpstreamId, time.Duration(lease.TTL)*time.Second, lease.MaxRemovals, nil)
+	if err != nil {
+		return removed, fmt.Errorf("%w: %w", ErrReap, err)
+	}
+	return removed, nil
+}
+
+// Reap 清理本服务上游中租约过期的节点，本实例的节点不会被删除
+func (s *Service) Reap(ctx context.Context) ([]string, error) {
+	keep := make(map[string]bool, 2)
+	for _, node := range s.nodes() {
+		keep[node.Key()] = true
+	}
+
+	removed, err := s.apiClient.reapStaleNodes(ctx, s.upstreamID, time.Duration(s.lease.TTL)*time.Second, s.lease.MaxRemovals, keep)
+	if err != nil {
+		return removed, fmt.Errorf("%w: %w", ErrReap, err)
+	}
+	return removed, nil
+}
+
+// startLease 启动后台续约，只会启动一次，s.ctx 取消后退出
+func (s *Service) startLease() {
+	if !s.lease.Enabled {
+		return
+	}
+
+	s.leaseOnce.Do(func() {
+		go s.leaseLoop()
+		s.logger.Info("节点租约已启动",
+			zap.Int("ttl_seconds", s.lease.TTL),
+			zap.Bool("reap", s.lease.Reap))
+	})
+}
+
+// leaseLoop 每 TTL/3 续约一次，开启 Reap 时顺带清理过期节点
+func (s *Service) leaseLoop() {
+	interval := time.Duration(s.lease.TTL) * time.Second / 3
+
+	for {
+		if err := sleepContext(s.ctx, jitter(interval)); err != nil {
+			s.logger.Info("节点租约已停止")
+			return
+		}
+
+		if err := s.renewLease(s.ctx); err != nil && s.ctx.Err() == nil {
+			s.logger.Warn("节点续约失败", zap.Error(err))
+		}
+
+		if !s.lease.Reap {
+			continue
+		}
+		if _, err := s.Reap(s.ctx); err != nil && s.ctx.Err() == nil {
+			s.logger.Warn("清理过期节点失败", zap.Error(err))
+		}
+	}
+}
+
+// renewLease 为本实例的节点续约，未注册或已注销时不续约
+func (s *Service) renewLease(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.registered {
+		return nil
+	}
+	return s.writeLease(ctx)
+}
+
+// writeLease 写入本实例所有节点的租约，调用方需持有 s.mu
+func (s *Service) writeLease(ctx context.Context) error {
+	now := time.Now()
+	for _, node := range s.nodes() {
+		if err := s.apiClient.renewLease(ctx, s.upstreamID, node.Key(), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package apisix_registration
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 就绪探测相关的默认参数
+const (
+	// DefaultReadyTimeout 默认等待本实例就绪的超时时间(秒)
+	DefaultReadyTimeout = 30
+
+	// defaultReadyProbeTimeout 单次探测的超时时间
+	defaultReadyProbeTimeout = 2 * time.Second
+
+	// defaultReadyMinBackoff 和 defaultReadyMaxBackoff 探测失败后的等待时间范围
+	defaultReadyMinBackoff = 100 * time.Millisecond
+	defaultReadyMaxBackoff = 2 * time.Second
+)
+
+// waitForReady 探测本实例的健康检查路由，直到返回2xx或超时
+// 未开启 WaitReady 时立即返回
+func (s *Service) waitForReady(ctx context.Context) error {
+	if !s.waitReady {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.readyTimeout)
+	defer cancel()
+
+	probeURL, probe := s.readyProbe()
+
+	s.logger.Info("等待本实例就绪后再注册",
+		zap.String("url", probeURL),
+		zap.Duration("timeout", s.readyTimeout))
+
+	start := time.Now()
+	var lastErr error
+	for failures := 0; ; failures++ {
+		lastErr = probe(ctx)
+		if lastErr == nil {
+			s.logger.Info("本实例已就绪",
+				zap.String("url", probeURL),
+				zap.Duration("elapsed", time.Since(start)))
+			return nil
+		}
+
+		wait := jitter(backoff(defaultReadyMinBackoff, failures, defaultReadyMaxBackoff))
+		if err := sleepContext(ctx, wait); err != nil {
+			return fmt.Errorf("%w: 等待 %s 返回2xx超时(%s)，最后一次探测: %w",
+				ErrNotReady, probeURL, time.Since(start).Round(time.Millisecond), lastErr)
+		}
+	}
+}
+
+// readyProbe 返回探测地址和单次探测函数
+// gRPC 模式下服务端口没有 HTTP 健康检查路由，未配置独立监听地址时通过 grpc.health.v1 探测服务端口
+func (s *Service) readyProbe() (string, func(ctx context.Context) error) {
+	if isGrpcScheme(s.upstream.Scheme) && !s.healthSvc.dedicated() {
+		target := s.healthSvc.probeAddr(s.host)
+		secure := s.upstream.Scheme == SchemeGRPCS
+		return s.upstream.Scheme + "://" + target, func(ctx context.Context) error {
+			return probeGrpcReady(ctx, target, secure)
+		}
+	}
+
+	probeURL := s.readyURL()
+	client := &http.Client{Timeout: defaultReadyProbeTimeout}
+	if s.readyTLS() {
+		// 只探测本实例，证书通常签发给对外域名而不是节点地址，因此不校验证书
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	method := s.healthSvc.methods[0]
+	return probeURL, func(ctx context.Context) error {
+		return probeReady(ctx, client, method, probeURL)
+	}
+}
+
+// readyURL 返回本实例健康检查路由的地址，配置了独立监听地址时探测该地址
+func (s *Service) readyURL() string {
+	scheme := "http://"
+	if s.readyTLS() {
+		scheme = "https://"
+	}
+	return scheme + s.healthSvc.probeAddr(s.host) + s.healthSvc.healthPath
+}
+
+// readyTLS 判断就绪探测是否需要使用 https，与 buildChecks 一致：
+// 服务端口使用 TLS 时健康检查路由也在 TLS 上，独立的健康检查端口提供的是 HTTP
+func (s *Service) readyTLS() bool {
+	return s.upstream.Scheme == SchemeHTTPS && !s.healthSvc.dedicated()
+}
+
+// probeReady 使用 method 请求一次健康检查路由，非2xx状态码视为未就绪
+func probeReady(ctx context.Context, client *http.Client, method, url string) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
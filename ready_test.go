@@ -0,0 +1,56 @@
+package apisix_registration
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadyProbeScheme(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	tests := []struct {
+		name       string
+		health     HealthCheckConfig
+		wantScheme string
+	}{
+		{name: "服务端口使用TLS", health: HealthCheckConfig{Path: "/health"}, wantScheme: "https://"},
+		{name: "独立健康检查端口使用HTTP", health: HealthCheckConfig{Path: "/health", Port: 9091}, wantScheme: "http://"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, "http://127.0.0.1:9180/apisix/admin", Config{
+				Name:      "svc",
+				Host:      host,
+				Port:      port,
+				Upstream:  Upstream{Id: "svc", Scheme: SchemeHTTPS},
+				HealthCfg: tt.health,
+			})
+
+			probeURL, probe := s.readyProbe()
+			if !strings.HasPrefix(probeURL, tt.wantScheme) {
+				t.Fatalf("探测地址 %s，期望以 %s 开头", probeURL, tt.wantScheme)
+			}
+			if tt.health.Port == 0 {
+				if err := probe(context.Background()); err != nil {
+					t.Errorf("探测 TLS 服务端口失败: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package apisix_registration
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultReconcileMaxBackoff 对账连续失败时的最大等待时间
+const defaultReconcileMaxBackoff = time.Minute
+
+// startReconciler 启动后台对账，只会启动一次，s.ctx 取消后退出
+func (s *Service) startReconciler() {
+	if s.interval <= 0 {
+		return
+	}
+
+	s.reconcileOnce.Do(func() {
+		go s.reconcileLoop()
+		s.logger.Info("节点对账已启动", zap.Int("interval_seconds", s.interval))
+	})
+}
+
+// reconcileLoop 定期对账，失败时按指数退避重试，所有等待都带有随机抖动
+func (s *Service) reconcileLoop() {
+	interval := time.Duration(s.interval) * time.Second
+	failures := 0
+
+	for {
+		wait := interval
+		if failures > 0 {
+			wait = backoff(interval, failures, defaultReconcileMaxBackoff)
+		}
+
+		if err := sleepContext(s.ctx, jitter(wait)); err != nil {
+			s.logger.Info("节点对账已停止")
+			return
+		}
+
+		if err := s.reconcile(s.ctx); err != nil {
+			if s.ctx.Err() != nil {
+				continue
+			}
+			failures++
+			s.logger.Warn("节点对账失败", zap.Error(err), zap.Int("failures", failures))
+			continue
+		}
+		failures = 0
+	}
+}
+
+// reconcile 检查本实例的节点和路由是否与期望一致，发现偏差时修复
+// 未注册或已注销时不做任何事，避免在注销之后又把节点加回去
+func (s *Service) reconcile(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.registered {
+		return nil
+	}
+
+	// 因健康检查失败被摘除的节点保持摘除状态，由健康监测负责恢复
+	for _, node := range s.nodes() {
+		if s.withdrawn {
+			if s.unhealthyAction == UnhealthyActionRemove {
+				continue
+			}
+			node.Weight = 0
+		}
+
+		repaired, err := s.apiClient.ensureNode(ctx, s.upstream, s.name, node)
+		if err != nil {
+			return err
+		}
+		if repaired {
+			s.logger.Info("已修复节点",
+				zap.String("upstream_id", s.upstreamID),
+				zap.String("node", node.Key()))
+		}
+	}
+
+	for _, route := range s.routes {
+		repaired, err := s.apiClient.ensureRoute(ctx, route, s.upstreamID)
+		if err != nil {
+			return err
+		}
+		if repaired {
+			s.logger.Info("已修复路由", zap.String("route_id", route.Id))
+		}
+	}
+
+	return nil
+}
+
+// backoff 返回第 failures 次失败后的等待时间，从 base 开始翻倍，不超过 max
+func backoff(base time.Duration, failures int, max time.Duration) time.Duration {
+	wait := base
+	for i := 0; i < failures && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait
+}
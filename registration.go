@@ -9,22 +9,65 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/linabellbiu/apisix-registration/admin"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 // 健康检查相关常量
 const (
+	// DefaultHost 已不再作为默认主机使用，未配置 Host 时通过 HostResolve 自动获取本机地址
 	DefaultHost              = "127.0.0.1"
 	DefaultHealthCheckMethod = "GET"
 	DefaultHealthCheckRoute  = "/health"
 	DefaultAdminApi          = "http://192.168.3.71:9180/apisix/admin"
 )
 
+// 上游负载均衡算法
+const (
+	UpstreamRoundRobin = "roundrobin" // 加权轮询（默认）
+	UpstreamCHash      = "chash"      // 一致性哈希，需要配合 HashOn 和 Key
+	UpstreamEWMA       = "ewma"       // 选择延迟最低的节点
+	UpstreamLeastConn  = "least_conn" // 选择活跃连接数最少的节点
+)
+
+// 上游协议
+const (
+	SchemeHTTP  = "http"  // HTTP（默认）
+	SchemeHTTPS = "https" // HTTPS
+	SchemeGRPC  = "grpc"  // gRPC，APISIX 通过 HTTP/2 明文转发
+	SchemeGRPCS = "grpcs" // 基于 TLS 的 gRPC
+)
+
+// 一致性哈希的哈希来源
+const (
+	HashOnVars             = "vars"              // Nginx 变量（默认）
+	HashOnHeader           = "header"            // 请求头
+	HashOnCookie           = "cookie"            // Cookie
+	HashOnConsumer         = "consumer"          // 消费者名称，不需要 Key
+	HashOnVarsCombinations = "vars_combinations" // 多个 Nginx 变量组合
+)
+
+// 路由清理策略
+const (
+	// RouteCleanupTeardown 仅在显式调用 Teardown 时删除路由（默认）
+	RouteCleanupTeardown = "teardown"
+	// RouteCleanupDeregister Deregister 时如果上游中只剩本实例的节点则删除路由，其他副本仍在时保留
+	RouteCleanupDeregister = "deregister"
+	// RouteCleanupNever 从不删除路由，由运维人员自行管理
+	RouteCleanupNever = "never"
+)
+
 type Service struct {
 	name        string
 	host        string
+	host6       string // 双栈部署时的 IPv6 地址
 	port        int
+	weight      int
+	priority    int
+	metadata    map[string]string
 	path        string
 	adminApi    string // APISIX Admin API 地址
 	apiKey      string // APISIX Admin API 密钥
@@ -32,42 +75,129 @@ type Service struct {
 	healthCheck bool
 	interval    int
 
+	waitReady    bool // 注册前是否等待本实例就绪
+	readyTimeout time.Duration
+
+	checksPolicy string // 上游已存在时 checks 的归属策略
+
+	unhealthyAction    string // 检查项连续失败时的处理
+	unhealthyThreshold int
+	healthyThreshold   int
+
+	upstream     Upstream
+	routes       []RouteConfig
+	routeCleanup string
+	drainPeriod  time.Duration
+	active       func() int64 // 本地服务正在处理的请求数
+
+	handleSignals bool
+
+	grpcHealth *grpcHealthServer // 已注册的 grpc.health.v1.Health 服务
+
 	apiClient *apisixClient
 	healthSvc *healthService
 	logger    *zap.Logger
 
-	mu     sync.Mutex
-	ctx    context.Context
-	cancel context.CancelFunc
+	mu            sync.Mutex
+	registered    bool // 是否已注册，注销后对账不再修复节点
+	withdrawn     bool // 是否因健康检查失败被摘除
+	reconcileOnce sync.Once
+	monitorOnce   sync.Once
+	lease         LeaseConfig
+	leaseOnce     sync.Once
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
 type Upstream struct {
 	Id            string `json:",optional"` // Id 自定义上游ID，如果为空则自动生成
-	UpstreamTypes string `json:",optional"` // UpstreamTypes 指定上游服务的类型。
+	UpstreamTypes string `json:",optional"` // UpstreamTypes 负载均衡算法: roundrobin(默认)、chash、ewma、least_conn
+	HashOn        string `json:",optional"` // HashOn 一致性哈希的来源，仅 chash 有效: vars(默认)、header、cookie、consumer、vars_combinations
+	Key           string `json:",optional"` // Key 一致性哈希的键，例如 remote_addr、cookie 名或请求头名，仅 chash 有效
+	Scheme        string `json:",optional"` // Scheme 上游协议: http(默认)、https、grpc、grpcs，grpc 和 grpcs 为 gRPC 模式
+
+	checks map[string]interface{} // 根据 HealthCfg 生成的上游健康检查，创建上游时写入
+}
+
+// RouteConfig 路由配置，注册时会创建指向本服务上游的路由
+type RouteConfig struct {
+	Id       string   `json:",optional"` // 路由ID，为空时根据服务名称和序号生成，配置路由时需要指定 Upstream.Id
+	Name     string   `json:",optional"` // 路由名称，为空时使用服务名称
+	Uri      string   `json:",optional"` // 匹配路径，与 Uris 至少填写一个
+	Uris     []string `json:",optional"` // 多个匹配路径
+	Methods  []string `json:",optional"` // 允许的请求方法，为空表示不限制，gRPC 模式下默认为 POST
+	Hosts    []string `json:",optional"` // 匹配的域名，为空表示不限制
+	Priority int      `json:",optional"` // 路由优先级，数值越大越优先
+
+	GrpcServices []string `json:",optional"` // gRPC 服务全名，例如 user.v1.UserService，生成 /user.v1.UserService/* 匹配路径，仅 gRPC 模式有效
 }
 
 // HealthCheckConfig 健康检查的配置
 type HealthCheckConfig struct {
-	Enabled bool   `json:",optional"` // 是否启用健康检查
-	Path    string `json:",optional"` // 健康检查路由
+	Enabled   bool   `json:",optional"` // 是否启用健康检查
+	Path      string `json:",optional"` // 健康检查路由，执行所有检查项
+	LivePath  string `json:",optional"` // 存活检查路由，只执行 Liveness 检查项，为空时只在内部服务器上使用 DefaultLivenessRoute，为 - 时关闭
+	ReadyPath string `json:",optional"` // 就绪检查路由，执行所有检查项，为空时只在内部服务器上使用 DefaultReadinessRoute，为 - 时关闭
+	Method    string `json:",optional"` // 健康检查响应的请求方法: GET(默认)、HEAD 或 GET,HEAD，其他方法返回 405
+
+	Port int    `json:",optional"` // 独立的健康检查端口，设置后健康检查不再占用服务端口
+	Addr string `json:",optional"` // 独立的健康检查监听地址，例如 0.0.0.0:9091，优先于 Port
+
+	WaitReady    bool `json:",optional"` // 注册前先探测本实例的健康检查路由，返回2xx后才添加节点
+	ReadyTimeout int  `json:",optional"` // 等待就绪的超时时间(秒)，默认为 DefaultReadyTimeout
+
+	Active       ActiveCheckConfig  `json:",optional"` // 开启健康检查时写入上游的 APISIX 主动检查
+	Passive      PassiveCheckConfig `json:",optional"` // APISIX 被动检查，可选
+	ChecksPolicy string             `json:",optional"` // 上游已存在时 checks 的归属策略: keep(默认)、merge、replace
+
+	UnhealthyAction    string `json:",optional"` // 检查项连续失败时的处理: weight0(默认)、remove、none
+	UnhealthyThreshold int    `json:",optional"` // 连续失败多少次后摘除节点，默认为 DefaultUnhealthyThreshold
+	HealthyThreshold   int    `json:",optional"` // 摘除后连续成功多少次恢复节点，默认为 DefaultHealthyThreshold
 }
 
 // Config 是服务配置
 type Config struct {
-	Enabled   bool              `json:",optional"`
-	Name      string            // 服务名称
-	Port      int               // 服务端口
-	Host      string            `json:",optional"` // 服务主机名
-	Upstream  Upstream          `json:",optional"`
-	AdminApi  string            `json:",optional"` // APISIX Admin API 地址
-	ApiKey    string            `json:",optional"` // APISIX Admin API 密钥
-	HealthCfg HealthCheckConfig `json:",optional"` // 健康检查配置
+	Enabled bool   `json:",optional"`
+	Name    string // 服务名称
+	Port    int    // 服务端口
+	Host    string `json:",optional"` // 服务主机名或IP，IPv6 地址可以带方括号
+	Host6   string `json:",optional"` // 双栈部署时本实例的 IPv6 地址，设置后 Host 和 Host6 都会注册为节点
+
+	HostResolve HostResolveConfig `json:",optional"` // 未配置 Host 时自动获取本机地址的策略
+	Weight      int               `json:",optional"` // 本实例节点权重，默认为1
+	Priority    int               `json:",optional"` // 本实例节点优先级，设置后上游使用数组格式的节点
+	Metadata    map[string]string `json:",optional"` // 本实例节点元数据，设置后上游使用数组格式的节点
+	Upstream    Upstream          `json:",optional"`
+	AdminApi    string            `json:",optional"` // APISIX Admin API 地址
+	ApiKey      string            `json:",optional"` // APISIX Admin API 密钥
+	HealthCfg   HealthCheckConfig `json:",optional"` // 健康检查配置
+
+	Lease LeaseConfig `json:",optional"` // 节点租约，实例异常退出未注销时由清理者删除节点
+
+	DrainPeriod int `json:",optional"` // 注销时先把节点权重改为0，等待该时长(秒)后再删除节点，0表示立即删除
+
+	Interval int `json:",optional"` // 对账间隔(秒)，定期检查节点和路由并修复偏差，默认为 DefaultHealthCheckInterval，小于0时关闭
+
+	Routes       []RouteConfig `json:",optional"` // 需要注册的路由
+	RouteCleanup string        `json:",optional"` // 路由清理策略: teardown(默认)、deregister、never
 
 	// 可以使用以下两种方式之一来集成自定义HTTP服务：
 	// 1. 使用标准HTTP服务器
 	httpServer *http.Server
 	// 2. 使用自定义健康检查处理器（支持不同框架）
 	healthHandler HealthHandler
+
+	// 自定义获取本机地址的函数，优先于 HostResolve 中的策略
+	hostResolver HostResolver
+
+	// 返回本地服务正在处理的请求数，设置后排空时会等待请求数归零
+	activeRequests func() int64
+
+	// 是否由本包监听 SIGINT 和 SIGTERM
+	handleSignals bool
+
+	// gRPC 模式下注册 grpc.health.v1.Health 服务的 gRPC 服务器
+	grpcServer *grpc.Server
 }
 
 type Option func(*Config)
@@ -84,6 +214,28 @@ func OptionsWithHealthHandler(health HealthHandler) Option {
 	}
 }
 
+// OptionsWithHostResolver 使用自定义函数获取本机地址，仅在未配置 Host 时生效
+func OptionsWithHostResolver(resolver HostResolver) Option {
+	return func(config *Config) {
+		config.hostResolver = resolver
+	}
+}
+
+// OptionsWithActiveRequests 设置本地服务正在处理的请求数，注销排空时会等待请求数归零再删除节点
+func OptionsWithActiveRequests(active func() int64) Option {
+	return func(config *Config) {
+		config.activeRequests = active
+	}
+}
+
+// OptionsWithSignalHandling 由本包监听 SIGINT 和 SIGTERM，收到信号后注销服务
+// 默认不监听信号，避免与 go-zero 的 proc 或业务自己的信号处理冲突
+func OptionsWithSignalHandling() Option {
+	return func(config *Config) {
+		config.handleSignals = true
+	}
+}
+
 // New 创建一个新的服务实例
 func New(cfg Config, o ...Option) (*Service, error) {
 	if !cfg.Enabled {
@@ -92,6 +244,10 @@ func New(cfg Config, o ...Option) (*Service, error) {
 	}
 	logger, _ := zap.NewProduction()
 
+	for _, f := range o {
+		f(&cfg)
+	}
+
 	if cfg.AdminApi == "" {
 		cfg.AdminApi = DefaultAdminApi
 	}
@@ -100,36 +256,134 @@ func New(cfg Config, o ...Option) (*Service, error) {
 		return nil, fmt.Errorf("%w: 服务名称不能为空", ErrInvalidConfig)
 	}
 
+	// 未配置主机时自动获取本机地址，不再回退到 APISIX 无法访问的 127.0.0.1
+	var (
+		host string
+		err  error
+	)
 	if cfg.Host == "" {
-		cfg.Host = DefaultHost
+		host, err = resolveHost(cfg.HostResolve, cfg.AdminApi, cfg.hostResolver)
+		if err == nil {
+			logger.Info("未指定主机，自动获取本机地址", zap.String("host", host))
+		}
+	} else {
+		host, err = normalizeHost(cfg.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg.Host = host
+
+	if cfg.Host6 != "" {
+		host6, err := normalizeHost(cfg.Host6)
+		if err != nil {
+			return nil, err
+		}
+		if !isIPv6(host6) {
+			return nil, fmt.Errorf("%w: Host6 必须是 IPv6 地址 %s", ErrInvalidConfig, cfg.Host6)
+		}
+		if host6 == cfg.Host {
+			return nil, fmt.Errorf("%w: Host6 与 Host 相同", ErrInvalidConfig)
+		}
+		cfg.Host6 = host6
 	}
 
 	if cfg.Port <= 0 {
 		return nil, ErrInvalidPort
 	}
 
+	if cfg.Weight < 0 {
+		return nil, fmt.Errorf("%w: 节点权重不能小于0", ErrInvalidConfig)
+	}
+	if cfg.Weight == 0 {
+		cfg.Weight = 1
+	}
+
 	// 生成或使用上游ID
+	// 自动生成的上游ID包含本实例地址，每个副本各有一个上游，共享的路由只能指向其中一个，因此配置路由时必须指定上游ID
 	upstreamID := cfg.Upstream.Id
+	if upstreamID == "" && len(cfg.Routes) > 0 {
+		return nil, fmt.Errorf("%w: 配置路由时需要指定 Upstream.Id，让所有副本共用同一个上游", ErrInvalidConfig)
+	}
 	if upstreamID == "" {
-		upstreamID = fmt.Sprintf("%s_%s_%d", cfg.Name, cfg.Host, cfg.Port)
+		upstreamID = sanitizeID(fmt.Sprintf("%s_%s_%d", cfg.Name, cfg.Host, cfg.Port))
 		logger.Info("未指定上游ID，自动生成", zap.String("upstream_id", upstreamID))
 	}
+	cfg.Upstream.Id = upstreamID
+
+	if err := validateUpstream(&cfg.Upstream); err != nil {
+		return nil, err
+	}
+
+	// 处理路由配置
+	switch cfg.RouteCleanup {
+	case "":
+		cfg.RouteCleanup = RouteCleanupTeardown
+	case RouteCleanupTeardown, RouteCleanupDeregister, RouteCleanupNever:
+	default:
+		return nil, fmt.Errorf("%w: 不支持的路由清理策略 %s", ErrInvalidConfig, cfg.RouteCleanup)
+	}
+
+	routes, err := buildRoutes(cfg.Name, cfg.Routes, isGrpcScheme(cfg.Upstream.Scheme))
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultHealthCheckInterval
+	}
+
+	if cfg.DrainPeriod < 0 {
+		return nil, fmt.Errorf("%w: 排空时长不能小于0", ErrInvalidConfig)
+	}
+
+	if err := validateLease(&cfg.Lease); err != nil {
+		return nil, err
+	}
 
 	// 处理健康检查配置
 	healthCheck := cfg.HealthCfg.Enabled
-	if cfg.HealthCfg.Enabled {
-		// 健康检查开启时，设置默认值
+	if cfg.HealthCfg.Enabled || cfg.HealthCfg.WaitReady {
+		// 健康检查或就绪探测开启时，设置默认值
 		if cfg.HealthCfg.Path == "" {
 			cfg.HealthCfg.Path = DefaultHealthCheckRoute
 		}
 	}
+	if cfg.HealthCfg.ReadyTimeout < 0 {
+		return nil, fmt.Errorf("%w: 等待就绪的超时时间不能小于0", ErrInvalidConfig)
+	}
+	if cfg.HealthCfg.ReadyTimeout == 0 {
+		cfg.HealthCfg.ReadyTimeout = DefaultReadyTimeout
+	}
+	if err := validateWithdraw(&cfg.HealthCfg); err != nil {
+		return nil, err
+	}
+	if err := validateHealthListener(&cfg.HealthCfg, cfg.Host, cfg.Host6, logger); err != nil {
+		return nil, err
+	}
+	if err := validateChecks(&cfg.HealthCfg); err != nil {
+		return nil, err
+	}
+	healthMethods, err := validateHealthMethod(&cfg.HealthCfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Upstream.checks = buildChecks(cfg.HealthCfg, cfg.Upstream.Scheme)
+	if cfg.grpcServer != nil && !isGrpcScheme(cfg.Upstream.Scheme) {
+		return nil, fmt.Errorf("%w: 注册 gRPC 健康检查服务需要 Upstream.Scheme 为 grpc 或 grpcs", ErrInvalidConfig)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	apiClient := newAPIClient(logger)
+	adminClient := admin.New(cfg.AdminApi, cfg.ApiKey)
+	apiClient := newAPIClient(adminClient, logger)
 	healthSvc := newHealthService(cfg.Name, cfg.Port, logger)
-
-	for _, f := range o {
-		f(&cfg)
+	healthSvc.livePath = cfg.HealthCfg.LivePath
+	healthSvc.readyPath = cfg.HealthCfg.ReadyPath
+	healthSvc.methods = healthMethods
+	healthSvc.setListener(cfg.HealthCfg.Addr, cfg.HealthCfg.Port)
+	if isGrpcScheme(cfg.Upstream.Scheme) {
+		// 服务端口由 gRPC 服务器监听，HTTP 健康检查只能通过独立监听地址或自定义处理器提供
+		healthSvc.markExternal()
 	}
 
 	// 设置健康检查服务
@@ -141,24 +395,152 @@ func New(cfg Config, o ...Option) (*Service, error) {
 		healthSvc.setCustomServer(cfg.httpServer, cfg.HealthCfg.Path)
 	}
 
-	return &Service{
-		apiKey:      cfg.ApiKey,
-		adminApi:    cfg.AdminApi,
-		name:        cfg.Name,
-		host:        cfg.Host,
-		port:        cfg.Port,
-		upstreamID:  upstreamID,
-		healthCheck: healthCheck,
-		apiClient:   apiClient,
-		healthSvc:   healthSvc,
-		logger:      logger,
-		ctx:         ctx,
-		cancel:      cancel,
-	}, nil
+	service := &Service{
+		apiKey:             cfg.ApiKey,
+		adminApi:           cfg.AdminApi,
+		name:               cfg.Name,
+		host:               cfg.Host,
+		host6:              cfg.Host6,
+		port:               cfg.Port,
+		weight:             cfg.Weight,
+		priority:           cfg.Priority,
+		metadata:           cfg.Metadata,
+		upstreamID:         upstreamID,
+		healthCheck:        healthCheck,
+		waitReady:          cfg.HealthCfg.WaitReady,
+		readyTimeout:       time.Duration(cfg.HealthCfg.ReadyTimeout) * time.Second,
+		checksPolicy:       cfg.HealthCfg.ChecksPolicy,
+		unhealthyAction:    cfg.HealthCfg.UnhealthyAction,
+		unhealthyThreshold: cfg.HealthCfg.UnhealthyThreshold,
+		healthyThreshold:   cfg.HealthCfg.HealthyThreshold,
+		interval:           cfg.Interval,
+		lease:              cfg.Lease,
+		drainPeriod:        time.Duration(cfg.DrainPeriod) * time.Second,
+		active:             cfg.activeRequests,
+		handleSignals:      cfg.handleSignals,
+		upstream:           cfg.Upstream,
+		routes:             routes,
+		routeCleanup:       cfg.RouteCleanup,
+		apiClient:          apiClient,
+		healthSvc:          healthSvc,
+		logger:             logger,
+		ctx:                ctx,
+		cancel:             cancel,
+	}
+	if cfg.grpcServer != nil {
+		service.RegisterGrpcHealth(cfg.grpcServer)
+	}
+	return service, nil
+}
+
+// validateUpstream 校验上游负载均衡配置并补全默认值
+func validateUpstream(upstream *Upstream) error {
+	switch upstream.Scheme {
+	case "", SchemeHTTP, SchemeHTTPS, SchemeGRPC, SchemeGRPCS:
+	default:
+		return fmt.Errorf("%w: 不支持的上游协议 %s", ErrInvalidConfig, upstream.Scheme)
+	}
+
+	switch upstream.UpstreamTypes {
+	case "":
+		upstream.UpstreamTypes = UpstreamRoundRobin
+	case UpstreamRoundRobin, UpstreamEWMA, UpstreamLeastConn:
+	case UpstreamCHash:
+		switch upstream.HashOn {
+		case "":
+			upstream.HashOn = HashOnVars
+		case HashOnVars, HashOnHeader, HashOnCookie, HashOnConsumer, HashOnVarsCombinations:
+		default:
+			return fmt.Errorf("%w: 不支持的 HashOn %s", ErrInvalidConfig, upstream.HashOn)
+		}
+		if upstream.Key == "" && upstream.HashOn != HashOnConsumer {
+			return fmt.Errorf("%w: chash 需要配置 Key", ErrInvalidConfig)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: 不支持的负载均衡算法 %s", ErrInvalidConfig, upstream.UpstreamTypes)
+	}
+
+	if upstream.HashOn != "" || upstream.Key != "" {
+		return fmt.Errorf("%w: HashOn 和 Key 仅在 chash 下有效", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// buildRoutes 校验路由配置并补全默认值，gRPC 模式下把 GrpcServices 转换为匹配路径
+func buildRoutes(serviceName string, cfgs []RouteConfig, grpcMode bool) ([]RouteConfig, error) {
+	routes := make([]RouteConfig, 0, len(cfgs))
+	seen := make(map[string]struct{}, len(cfgs))
+
+	for i, route := range cfgs {
+		if len(route.GrpcServices) > 0 {
+			if !grpcMode {
+				return nil, fmt.Errorf("%w: 第%d个路由配置了 GrpcServices，但上游不是 gRPC 协议", ErrInvalidConfig, i+1)
+			}
+			uris, err := grpcServiceURIs(route.GrpcServices)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 第%d个路由: %w", ErrInvalidConfig, i+1, err)
+			}
+			route.Uris = append(append([]string(nil), route.Uris...), uris...)
+		}
+		if route.Uri == "" && len(route.Uris) == 0 {
+			return nil, fmt.Errorf("%w: 第%d个路由未配置 Uri、Uris 或 GrpcServices", ErrInvalidConfig, i+1)
+		}
+		if grpcMode && len(route.Methods) == 0 {
+			// gRPC 请求都使用 POST
+			route.Methods = []string{http.MethodPost}
+		}
+		if route.Id == "" {
+			route.Id = sanitizeID(fmt.Sprintf("%s_route_%d", serviceName, i+1))
+		}
+		if route.Name == "" {
+			route.Name = serviceName
+		}
+		if _, ok := seen[route.Id]; ok {
+			return nil, fmt.Errorf("%w: 路由ID重复 %s", ErrInvalidConfig, route.Id)
+		}
+		seen[route.Id] = struct{}{}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// nodes 返回本实例在上游中的节点，双栈部署时包含 IPv4 和 IPv6 两个节点
+func (s *Service) nodes() []admin.Node {
+	nodes := []admin.Node{s.node(s.host)}
+	if s.host6 != "" {
+		nodes = append(nodes, s.node(s.host6))
+	}
+	return nodes
+}
+
+// node 返回本实例指定地址的节点
+func (s *Service) node(host string) admin.Node {
+	node := admin.Node{
+		Host:     host,
+		Port:     s.port,
+		Weight:   s.weight,
+		Priority: s.priority,
+	}
+	if len(s.metadata) > 0 {
+		node.Metadata = make(map[string]interface{}, len(s.metadata))
+		for k, v := range s.metadata {
+			node.Metadata[k] = v
+		}
+	}
+	return node
 }
 
 // Register 注册服务到APISIX
 func (s *Service) Register() error {
+	return s.RegisterContext(context.Background())
+}
+
+// RegisterContext 注册服务到APISIX，ctx 取消或超时后立即中止请求和重试
+func (s *Service) RegisterContext(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -170,21 +552,40 @@ func (s *Service) Register() error {
 		s.logger.Warn("未提供API密钥，这可能会导致认证失败")
 	}
 
-	err := s.apiClient.createUpstream(
-		s.adminApi,
-		s.apiKey,
-		s.upstreamID,
-		s.name,
-		s.host,
-		s.port,
-	)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrCreateUpstream, err)
+	for _, node := range s.nodes() {
+		err := s.apiClient.createUpstream(
+			ctx,
+			s.upstream,
+			s.name,
+			node,
+		)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrCreateUpstream, err)
+		}
+	}
+
+	if err := s.apiClient.syncChecks(ctx, s.upstreamID, s.upstream.checks, s.checksPolicy); err != nil {
+		return fmt.Errorf("%w: %w", ErrCreateUpstream, err)
+	}
+
+	if s.lease.Enabled {
+		if err := s.writeLease(ctx); err != nil {
+			return fmt.Errorf("%w: %w", ErrRenewLease, err)
+		}
+	}
+
+	for _, route := range s.routes {
+		if err := s.apiClient.createRoute(ctx, route, s.upstreamID); err != nil {
+			return fmt.Errorf("%w: %w", ErrCreateRoute, err)
+		}
 	}
+	s.registered = true
+	s.withdrawn = false
 
 	s.logger.Info("服务已成功注册到APISIX",
 		zap.String("service", s.name),
 		zap.String("host", s.host),
+		zap.String("host6", s.host6),
 		zap.Int("port", s.port),
 		zap.String("upstream_id", s.upstreamID),
 	)
@@ -202,89 +603,256 @@ func (s *Service) StartHealthCheck() error {
 	}
 
 	if err := s.healthSvc.start(); err != nil {
-		return fmt.Errorf("%w: %v", ErrStartHealthCheck, err)
+		return fmt.Errorf("%w: %w", ErrStartHealthCheck, err)
 	}
 
 	return nil
 }
 
-// Start 启动服务
+// Start 注册服务并启动健康检查和后台任务后立即返回
+// 设置 OptionsWithSignalHandling 时会在收到 SIGINT 或 SIGTERM 后异步注销，否则需要自行调用 Deregister 和 Shutdown
 func (s *Service) Start() error {
-	if err := s.Register(); err != nil {
+	if err := s.start(context.Background()); err != nil {
+		return err
+	}
+
+	if s.handleSignals {
+		go func() {
+			quit := make(chan os.Signal, 1)
+			signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+			<-quit
+
+			s.logger.Info("关闭信号已接收，开始注册服务关闭")
+			_ = s.stop()
+		}()
+	}
+
+	s.logger.Info("注册服务已启动")
+	return nil
+}
+
+// Run 注册服务并阻塞到 ctx 结束，然后同步注销服务并关闭健康检查服务
+// 设置 OptionsWithSignalHandling 时收到 SIGINT 或 SIGTERM 也会结束等待
+func (s *Service) Run(ctx context.Context) error {
+	// 未开启注册时 New 返回 nil，此时只等待 ctx 结束
+	if s == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	if s.handleSignals {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+	}
+
+	if err := s.start(ctx); err != nil {
 		return err
 	}
+	s.logger.Info("注册服务已启动")
+
+	<-ctx.Done()
+
+	s.logger.Info("开始注册服务关闭")
+	return s.stop()
+}
 
+// start 启动健康检查服务，注册服务并启动后台任务
+// 健康检查服务先于注册启动，避免 APISIX 把流量转发到还没有监听的端口
+func (s *Service) start(ctx context.Context) error {
 	// 启动健康检查服务
 	if err := s.StartHealthCheck(); err != nil {
 		s.logger.Error("启动健康检查失败")
 		return err
 	}
 
-	go func() {
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	err := s.waitForReady(ctx)
+	if err == nil {
+		if err = s.RegisterContext(ctx); err != nil {
+			s.rollbackRegister()
+		}
+	}
+	if err != nil {
+		// 只关闭健康检查服务器，不取消后台任务的 ctx，调用方重试 Start 或 Run 后后台任务仍能正常运行
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+		defer cancel()
+		if shutdownErr := s.shutdownHealth(shutdownCtx); shutdownErr != nil {
+			s.logger.Error("关闭健康检查服务失败", zap.Error(shutdownErr))
+		}
+		return err
+	}
+
+	// 启动后台对账，节点或路由被删除、权重被修改后自动修复
+	s.startReconciler()
+	s.startLease()
+	s.startHealthMonitor()
+	return nil
+}
 
-		<-quit
+// rollbackRegister 注册中途失败时删除已经写入上游的节点，避免 APISIX 把流量转发到启动失败的实例
+// 路由由所有副本共用，这里不删除
+func (s *Service) rollbackRegister() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout+s.drainPeriod)
+	defer cancel()
 
-		s.logger.Info("关闭信号已接收，开始注册服务关闭")
-		s.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		// 从APISIX注销
-		if err := s.Deregister(); err != nil {
-			s.logger.Error("从APISIX注销失败", zap.Error(err))
-		}
+	if err := s.deregister(ctx, false); err != nil {
+		s.logger.Error("删除注册失败的节点失败", zap.Error(err))
+	}
+}
 
-		// 关闭健康检查服务
-		ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
-		defer cancel()
+// stop 停止后台任务，注销服务并关闭健康检查服务
+func (s *Service) stop() error {
+	s.cancel()
 
-		if err := s.Shutdown(ctx); err != nil {
-			s.logger.Error("关闭服务失败", zap.Error(err))
-		}
+	// 注销和关闭健康检查共用同一个超时，避免 Admin API 缓慢时阻塞退出，排空时长另外计算
+	// 不能使用已经结束的调用方 ctx，否则注销请求会立即失败
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout+s.drainPeriod)
+	defer cancel()
 
-		s.logger.Info("服务已完全关闭")
-	}()
+	// 从APISIX注销
+	deregisterErr := s.DeregisterContext(ctx)
+	if deregisterErr != nil {
+		s.logger.Error("从APISIX注销失败", zap.Error(deregisterErr))
+	}
 
-	s.logger.Info("注册服务已启动")
-	return nil
+	// 关闭健康检查服务
+	shutdownErr := s.Shutdown(ctx)
+	if shutdownErr != nil {
+		s.logger.Error("关闭服务失败", zap.Error(shutdownErr))
+	}
+
+	s.logger.Info("服务已完全关闭")
+
+	if deregisterErr != nil {
+		return deregisterErr
+	}
+	return shutdownErr
 }
 
 // Deregister 从APISIX注销服务
+// 只删除本实例的节点，路由是否删除取决于 RouteCleanup 策略
 func (s *Service) Deregister() error {
+	return s.DeregisterContext(context.Background())
+}
+
+// DeregisterContext 从APISIX注销服务，ctx 取消或超时后立即中止请求和重试
+func (s *Service) DeregisterContext(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deregister(ctx, s.routeCleanup == RouteCleanupDeregister && len(s.routes) > 0 && s.lastInstance(ctx))
+}
+
+// lastInstance 判断上游中是否只剩本实例的节点，调用方需持有锁
+// 路由由共用上游的所有副本共用，其他副本仍在时删除路由会让整个服务不可用；无法确认时保留路由
+func (s *Service) lastInstance(ctx context.Context) bool {
+	nodeKeys := make([]string, 0, 2)
+	for _, node := range s.nodes() {
+		nodeKeys = append(nodeKeys, node.Key())
+	}
+
+	last, err := s.apiClient.onlyNodes(ctx, s.upstreamID, nodeKeys)
+	if err != nil {
+		s.logger.Warn("无法确认是否为最后一个实例，保留路由", zap.Error(err))
+		return false
+	}
+	return last
+}
+
+// Teardown 注销本实例节点并删除配置的路由
+// 适用于服务整体下线的场景，RouteCleanup 为 never 时不删除路由
+func (s *Service) Teardown() error {
+	return s.TeardownContext(context.Background())
+}
+
+// TeardownContext 与 Teardown 相同，ctx 取消或超时后立即中止请求和重试
+func (s *Service) TeardownContext(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.deregister(ctx, s.routeCleanup != RouteCleanupNever)
+}
+
+// deregister 删除本实例节点，deleteRoutes 为 true 时同时删除路由，调用方需持有锁
+func (s *Service) deregister(ctx context.Context, deleteRoutes bool) error {
 	if s.adminApi == "" {
 		return ErrEmptyAdminAPI
 	}
 
-	nodeKey := fmt.Sprintf("%s:%d", s.host, s.port)
+	// 先删除路由，避免路由指向一个即将没有节点的上游
+	if deleteRoutes {
+		for _, route := range s.routes {
+			if err := s.apiClient.deleteRoute(ctx, route.Id); err != nil {
+				return fmt.Errorf("%w: %w", ErrDeleteRoute, err)
+			}
+		}
+	}
+
+	nodeKeys := make([]string, 0, 2)
+	for _, node := range s.nodes() {
+		nodeKeys = append(nodeKeys, node.Key())
+	}
+
+	// 先标记为未注册，即使删除失败，对账也不会再把节点加回去
+	s.registered = false
+
 	if s.upstreamID != "" {
-		err := s.apiClient.deleteNode(s.adminApi, s.apiKey, s.upstreamID, nodeKey)
-		if err != nil {
-			return fmt.Errorf("%w: %v", ErrDeleteNode, err)
+		s.drain(ctx, nodeKeys)
+
+		for _, nodeKey := range nodeKeys {
+			if err := s.apiClient.deleteNode(ctx, s.upstreamID, nodeKey); err != nil {
+				return fmt.Errorf("%w: %w", ErrDeleteNode, err)
+			}
+			// 节点已经删除，租约标签残留也会在下次清理时被删除，这里失败只记录日志
+			if s.lease.Enabled {
+				if err := s.apiClient.releaseLease(ctx, s.upstreamID, nodeKey); err != nil {
+					s.logger.Warn("删除节点租约失败", zap.String("node", nodeKey), zap.Error(err))
+				}
+			}
 		}
 	}
 
 	s.logger.Info("服务节点已从APISIX注销",
 		zap.String("service", s.name),
 		zap.String("upstream_id", s.upstreamID),
-		zap.String("node", nodeKey),
+		zap.Strings("nodes", nodeKeys),
+		zap.Bool("routes_deleted", deleteRoutes && len(s.routes) > 0),
 	)
 
 	return nil
 }
 
-// Shutdown 关闭服务
+// Shutdown 停止对账、租约和健康监测等后台任务并关闭健康检查服务，
+// 已注册的 gRPC 健康检查服务之后返回 NOT_SERVING
 func (s *Service) Shutdown(ctx context.Context) error {
+	// 后台任务使用 s.ctx，不取消的话关闭之后对账和租约清理仍会继续修改 APISIX
+	s.cancel()
+
+	if s.grpcHealth != nil {
+		s.grpcHealth.shutdown()
+	}
+	return s.shutdownHealth(ctx)
+}
+
+// shutdownHealth 只关闭内部健康检查服务器，之后可以重新调用 StartHealthCheck
+func (s *Service) shutdownHealth(ctx context.Context) error {
 	if s.healthCheck {
 		if err := s.healthSvc.shutdown(ctx); err != nil {
-			return fmt.Errorf("%w: %v", ErrShutdownServer, err)
+			return fmt.Errorf("%w: %w", ErrShutdownServer, err)
 		}
 	}
 	return nil
 }
 
+// Admin 返回服务注册使用的 Admin API 客户端，可用于管理其他 APISIX 资源
+func (s *Service) Admin() *admin.Client {
+	return s.apiClient.admin
+}
+
 // SetHealthHandler 设置自定义健康检查处理器
 func (s *Service) SetHealthHandler(handler HealthHandler, healthPath string) {
 	s.mu.Lock()
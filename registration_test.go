@@ -0,0 +1,174 @@
+package apisix_registration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownStopsBackgroundTasks(t *testing.T) {
+	_, adminAPI := newFakeAdmin(t, 0)
+	s := newTestService(t, adminAPI, Config{
+		Name:     "svc",
+		Host:     "10.0.0.1",
+		Port:     8080,
+		Upstream: Upstream{Id: "svc"},
+		Lease:    LeaseConfig{Enabled: true},
+	})
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("启动服务失败: %v", err)
+	}
+	if err := s.Deregister(); err != nil {
+		t.Fatalf("注销服务失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("关闭服务失败: %v", err)
+	}
+
+	select {
+	case <-s.ctx.Done():
+	default:
+		t.Fatal("Shutdown 之后后台任务的 ctx 没有被取消")
+	}
+}
+
+func TestStartRetryKeepsBackgroundTasks(t *testing.T) {
+	fake, adminAPI := newFakeAdmin(t, 0)
+	s := newTestService(t, adminAPI, Config{
+		Name:     "svc",
+		Host:     "10.0.0.1",
+		Port:     8080,
+		Interval: 1,
+		Upstream: Upstream{Id: "svc"},
+	})
+	defer func() { _ = s.stop() }()
+
+	// 第一次启动时 Admin API 不可用，恢复后重试
+	fake.fail("", http.StatusUnauthorized, "failed to check token")
+	if err := s.Start(); err == nil {
+		t.Fatal("Admin API 不可用时启动应该失败")
+	}
+	fake.fail("", 0, "")
+	if err := s.Start(); err != nil {
+		t.Fatalf("重试启动失败: %v", err)
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		t.Fatalf("重试启动后后台任务的 ctx 已结束: %v", err)
+	}
+
+	// 删除上游后对账应该把节点加回去
+	fake.mu.Lock()
+	delete(fake.resources, "upstreams/svc")
+	fake.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.mu.Lock()
+		_, ok := fake.resources["upstreams/svc"]
+		fake.mu.Unlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("重试启动后对账没有运行，上游未被恢复")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestStartFailureRemovesNodes(t *testing.T) {
+	fake, adminAPI := newFakeAdmin(t, 0)
+	s := newTestService(t, adminAPI, Config{
+		Name:     "svc",
+		Host:     "10.0.0.1",
+		Port:     8080,
+		Upstream: Upstream{Id: "svc"},
+		Routes:   []RouteConfig{{Uri: "/svc/*"}},
+	})
+
+	// 节点写入之后创建路由失败
+	fake.fail("routes/", http.StatusForbidden, "permission denied")
+	if err := s.Start(); !errors.Is(err, ErrCreateRoute) {
+		t.Fatalf("Start() = %v，期望 ErrCreateRoute", err)
+	}
+
+	if nodes := fake.upstreamNodes(t, "svc"); len(nodes.List()) != 0 {
+		t.Errorf("启动失败后节点仍在上游中: %+v", nodes.List())
+	}
+}
+
+func TestDeregisterKeepsSharedRoutes(t *testing.T) {
+	fake, adminAPI := newFakeAdmin(t, 0)
+	services := registerReplicas(t, adminAPI, 2, Config{
+		Name:         "svc",
+		Port:         8080,
+		Upstream:     Upstream{Id: "shared"},
+		Routes:       []RouteConfig{{Id: "svc-route", Uri: "/svc/*"}},
+		RouteCleanup: RouteCleanupDeregister,
+	})
+
+	routeExists := func() bool {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		_, ok := fake.resources["routes/svc-route"]
+		return ok
+	}
+
+	// 其他副本仍在上游中，路由需要保留
+	if err := services[0].Deregister(); err != nil {
+		t.Fatalf("注销第一个副本失败: %v", err)
+	}
+	if !routeExists() {
+		t.Fatal("其他副本仍在时路由被删除")
+	}
+
+	// 最后一个副本注销时删除路由
+	if err := services[1].Deregister(); err != nil {
+		t.Fatalf("注销最后一个副本失败: %v", err)
+	}
+	if routeExists() {
+		t.Error("最后一个副本注销后路由仍然存在")
+	}
+}
+
+func TestRegisterAPIError(t *testing.T) {
+	fake, adminAPI := newFakeAdmin(t, 0)
+	s := newTestService(t, adminAPI, Config{
+		Name:     "svc",
+		Host:     "10.0.0.1",
+		Port:     8080,
+		Upstream: Upstream{Id: "svc"},
+	})
+
+	fake.fail("upstreams/svc", http.StatusUnauthorized, "failed to check token")
+	err := s.Register()
+	if !errors.Is(err, ErrCreateUpstream) {
+		t.Fatalf("Register() = %v，期望 ErrCreateUpstream", err)
+	}
+	if !IsUnauthorized(err) {
+		t.Errorf("IsUnauthorized(%v) = false", err)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Register() = %v，期望包含 *APIError", err)
+	}
+	want := APIError{
+		StatusCode: http.StatusUnauthorized,
+		ErrorMsg:   "failed to check token",
+		Method:     http.MethodGet,
+		Path:       "/apisix/admin/upstreams/svc",
+		Resource:   "upstreams",
+	}
+	if *apiErr != want {
+		t.Errorf("APIError = %+v，期望 %+v", *apiErr, want)
+	}
+}
@@ -0,0 +1,246 @@
+package apisix_registration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/linabellbiu/apisix-registration/admin"
+	"go.uber.org/zap"
+)
+
+// 上游已存在时 checks 的归属策略
+const (
+	// ChecksPolicyKeep 上游已有主动检查时保持不变，没有时写入（默认），适合运维人员在 APISIX 中调整过检查参数的场景
+	ChecksPolicyKeep = "keep"
+	// ChecksPolicyMerge 把配置的字段合并进已有的 checks，配置中没有的字段保留
+	ChecksPolicyMerge = "merge"
+	// ChecksPolicyReplace checks 完全由本服务的配置决定，整体替换已有的 checks
+	ChecksPolicyReplace = "replace"
+)
+
+// DefaultActiveCheckTimeout 默认主动检查的超时时间(秒)
+const DefaultActiveCheckTimeout = 1
+
+// ActiveCheckConfig APISIX 对节点的主动健康检查配置
+type ActiveCheckConfig struct {
+	Interval           int     `json:",optional"` // 探测间隔(秒)，默认为 DefaultHealthCheckInterval
+	Timeout            float64 `json:",optional"` // 探测超时(秒)，默认为 DefaultActiveCheckTimeout
+	HealthyThreshold   int     `json:",optional"` // 连续成功多少次标记为健康，默认为 DefaultHealthyThreshold
+	UnhealthyThreshold int     `json:",optional"` // 连续失败多少次标记为不健康，默认为 DefaultUnhealthyThreshold
+	Host               string  `json:",optional"` // 探测请求的 Host 头，默认使用节点地址
+	HttpStatuses       []int   `json:",optional"` // 视为健康的状态码，默认使用 APISIX 的默认值
+}
+
+// PassiveCheckConfig APISIX 根据实际请求结果进行的被动健康检查配置，依赖主动检查
+type PassiveCheckConfig struct {
+	Enabled            bool  `json:",optional"` // 是否开启被动检查
+	HealthyStatuses    []int `json:",optional"` // 视为健康的状态码，默认使用 APISIX 的默认值
+	UnhealthyStatuses  []int `json:",optional"` // 视为不健康的状态码，默认使用 APISIX 的默认值
+	UnhealthyThreshold int   `json:",optional"` // 连续失败多少次标记为不健康，默认为 DefaultUnhealthyThreshold
+}
+
+// validateChecks 校验上游健康检查配置并补全默认值
+func validateChecks(cfg *HealthCheckConfig) error {
+	switch cfg.ChecksPolicy {
+	case "":
+		cfg.ChecksPolicy = ChecksPolicyKeep
+	case ChecksPolicyKeep, ChecksPolicyMerge, ChecksPolicyReplace:
+	default:
+		return fmt.Errorf("%w: 不支持的 checks 归属策略 %s", ErrInvalidConfig, cfg.ChecksPolicy)
+	}
+
+	active := &cfg.Active
+	if active.Interval < 0 || active.Timeout < 0 || active.HealthyThreshold < 0 || active.UnhealthyThreshold < 0 ||
+		cfg.Passive.UnhealthyThreshold < 0 {
+		return fmt.Errorf("%w: 主动和被动检查的参数不能小于0", ErrInvalidConfig)
+	}
+	if active.Interval == 0 {
+		active.Interval = DefaultHealthCheckInterval
+	}
+	if active.Timeout == 0 {
+		active.Timeout = DefaultActiveCheckTimeout
+	}
+	if active.HealthyThreshold == 0 {
+		active.HealthyThreshold = DefaultHealthyThreshold
+	}
+	if active.UnhealthyThreshold == 0 {
+		active.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	if cfg.Passive.UnhealthyThreshold == 0 {
+		cfg.Passive.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	return nil
+}
+
+// buildChecks 根据健康检查配置生成上游的 checks，未开启健康检查时返回 nil
+// APISIX 无法发起 gRPC 健康检查，gRPC 上游没有独立的 HTTP 健康检查端口时使用 TCP 检查
+func buildChecks(cfg HealthCheckConfig, scheme string) map[string]interface{} {
+	if !cfg.Enabled {
+		return nil
+	}
+	if isGrpcScheme(scheme) && cfg.Port == 0 {
+		return buildTCPChecks(cfg)
+	}
+
+	a := cfg.Active
+	healthy := map[string]interface{}{
+		"interval":  a.Interval,
+		"successes": a.HealthyThreshold,
+	}
+	if len(a.HttpStatuses) > 0 {
+		healthy["http_statuses"] = a.HttpStatuses
+	}
+
+	// APISIX 主动检查固定使用 GET 探测 http_path，validateHealthMethod 保证健康检查路由响应 GET
+	active := map[string]interface{}{
+		"type":      "http",
+		"http_path": cfg.Path,
+		"timeout":   a.Timeout,
+		"healthy":   healthy,
+		"unhealthy": map[string]interface{}{
+			"interval":      a.Interval,
+			"http_failures": a.UnhealthyThreshold,
+			"tcp_failures":  a.UnhealthyThreshold,
+			"timeouts":      a.UnhealthyThreshold,
+		},
+	}
+	if a.Host != "" {
+		active["host"] = a.Host
+	}
+	// 服务端口使用 TLS 时探测服务端口需要 https 检查，独立的健康检查端口提供的是 HTTP
+	if scheme == SchemeHTTPS && cfg.Port == 0 {
+		active["type"] = "https"
+	}
+	// 独立的健康检查端口需要告诉 APISIX，否则会探测节点的服务端口
+	if cfg.Port > 0 {
+		active["port"] = cfg.Port
+	}
+
+	checks := map[string]interface{}{"active": active}
+
+	if p := cfg.Passive; p.Enabled {
+		passiveUnhealthy := map[string]interface{}{
+			"http_failures": p.UnhealthyThreshold,
+			"tcp_failures":  p.UnhealthyThreshold,
+			"timeouts":      p.UnhealthyThreshold,
+		}
+		if len(p.UnhealthyStatuses) > 0 {
+			passiveUnhealthy["http_statuses"] = p.UnhealthyStatuses
+		}
+		passive := map[string]interface{}{
+			"type":      "http",
+			"unhealthy": passiveUnhealthy,
+		}
+		if len(p.HealthyStatuses) > 0 {
+			passive["healthy"] = map[string]interface{}{"http_statuses": p.HealthyStatuses}
+		}
+		checks["passive"] = passive
+	}
+
+	return checks
+}
+
+// buildTCPChecks 生成只检查端口能否建立连接的 checks，用于没有 HTTP 健康检查路由的 gRPC 上游
+func buildTCPChecks(cfg HealthCheckConfig) map[string]interface{} {
+	a := cfg.Active
+	checks := map[string]interface{}{
+		"active": map[string]interface{}{
+			"type":    "tcp",
+			"timeout": a.Timeout,
+			"healthy": map[string]interface{}{
+				"interval":  a.Interval,
+				"successes": a.HealthyThreshold,
+			},
+			"unhealthy": map[string]interface{}{
+				"interval":     a.Interval,
+				"tcp_failures": a.UnhealthyThreshold,
+				"timeouts":     a.UnhealthyThreshold,
+			},
+		},
+	}
+
+	if p := cfg.Passive; p.Enabled {
+		checks["passive"] = map[string]interface{}{
+			"type": "tcp",
+			"unhealthy": map[string]interface{}{
+				"tcp_failures": p.UnhealthyThreshold,
+				"timeouts":     p.UnhealthyThreshold,
+			},
+		}
+	}
+
+	return checks
+}
+
+// syncChecks 按归属策略把 checks 写入已存在的上游，与期望一致时不写入
+//
+// 合并在本地完成后通过 /upstreams/{id}/checks 子路径整体写回，APISIX 2.x 和 3.x 的行为一致。
+func (c *apisixClient) syncChecks(ctx context.Context, upstreamID string, checks map[string]interface{}, policy string) error {
+	if checks == nil {
+		return nil
+	}
+
+	upstream, err := c.admin.Upstreams().Get(ctx, upstreamID)
+	if admin.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("获取上游信息失败: %w", err)
+	}
+
+	desired := normalizeJSON(checks)
+	switch policy {
+	case ChecksPolicyKeep:
+		if _, ok := upstream.Checks["active"]; ok {
+			return nil
+		}
+		desired = mergeJSON(normalizeJSON(upstream.Checks), desired)
+	case ChecksPolicyMerge:
+		desired = mergeJSON(normalizeJSON(upstream.Checks), desired)
+	}
+
+	if reflect.DeepEqual(normalizeJSON(upstream.Checks), desired) {
+		return nil
+	}
+
+	if _, err := c.admin.Upstreams().PatchPath(ctx, upstreamID, desired, "checks"); err != nil {
+		return fmt.Errorf("更新上游健康检查失败: %w", err)
+	}
+
+	c.logger.Info("已更新上游健康检查",
+		zap.String("upstream_id", upstreamID),
+		zap.String("policy", policy))
+	return nil
+}
+
+// normalizeJSON 把值转换为 JSON 解码后的通用结构，便于比较和合并
+func normalizeJSON(v interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	if v == nil {
+		return out
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return out
+	}
+	_ = json.Unmarshal(body, &out)
+	if out == nil {
+		out = map[string]interface{}{}
+	}
+	return out
+}
+
+// mergeJSON 把 src 递归合并进 dst，对象按字段合并，其他类型整体替换
+func mergeJSON(dst, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		sm, ok := v.(map[string]interface{})
+		if dm, exists := dst[k].(map[string]interface{}); ok && exists {
+			dst[k] = mergeJSON(dm, sm)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
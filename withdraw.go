@@ -0,0 +1,163 @@
+package apisix_registration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 检查项连续失败时对节点的处理
+const (
+	// UnhealthyActionWeightZero 把节点权重改为0，保留节点（默认）
+	UnhealthyActionWeightZero = "weight0"
+	// UnhealthyActionRemove 从上游删除节点
+	UnhealthyActionRemove = "remove"
+	// UnhealthyActionNone 只记录日志，不修改节点
+	UnhealthyActionNone = "none"
+)
+
+// 摘除和恢复节点的默认阈值
+const (
+	// DefaultUnhealthyThreshold 默认连续失败多少次后摘除节点
+	DefaultUnhealthyThreshold = 3
+
+	// DefaultHealthyThreshold 默认摘除后连续成功多少次恢复节点
+	DefaultHealthyThreshold = 2
+)
+
+// validateWithdraw 校验摘除和恢复配置并补全默认值
+func validateWithdraw(cfg *HealthCheckConfig) error {
+	switch cfg.UnhealthyAction {
+	case "":
+		cfg.UnhealthyAction = UnhealthyActionWeightZero
+	case UnhealthyActionWeightZero, UnhealthyActionRemove, UnhealthyActionNone:
+	default:
+		return fmt.Errorf("%w: 不支持的不健康处理方式 %s", ErrInvalidConfig, cfg.UnhealthyAction)
+	}
+
+	if cfg.UnhealthyThreshold < 0 || cfg.HealthyThreshold < 0 {
+		return fmt.Errorf("%w: 健康阈值不能小于0", ErrInvalidConfig)
+	}
+	if cfg.UnhealthyThreshold == 0 {
+		cfg.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	if cfg.HealthyThreshold == 0 {
+		cfg.HealthyThreshold = DefaultHealthyThreshold
+	}
+	return nil
+}
+
+// startHealthMonitor 启动后台健康监测，只会启动一次，s.ctx 取消后退出
+func (s *Service) startHealthMonitor() {
+	if s.unhealthyAction == UnhealthyActionNone && !s.healthSvc.hasCheckers() {
+		return
+	}
+
+	s.monitorOnce.Do(func() {
+		go s.healthMonitorLoop()
+		s.logger.Info("健康监测已启动",
+			zap.String("unhealthy_action", s.unhealthyAction),
+			zap.Int("unhealthy_threshold", s.unhealthyThreshold),
+			zap.Int("healthy_threshold", s.healthyThreshold))
+	})
+}
+
+// healthMonitorLoop 定期执行检查项，连续失败达到阈值时摘除节点，摘除后连续成功达到阈值时恢复
+func (s *Service) healthMonitorLoop() {
+	interval := time.Duration(s.interval) * time.Second
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval * time.Second
+	}
+
+	failures, successes := 0, 0
+	for {
+		if err := sleepContext(s.ctx, jitter(interval)); err != nil {
+			s.logger.Info("健康监测已停止")
+			return
+		}
+
+		if !s.healthSvc.hasCheckers() {
+			continue
+		}
+
+		report := s.healthSvc.report(s.ctx)
+		if s.ctx.Err() != nil {
+			continue
+		}
+
+		if !report.healthy() {
+			failures++
+			successes = 0
+			for _, r := range report.Checks {
+				if r.Status == checkStatusOK {
+					continue
+				}
+				s.logger.Warn("健康检查项失败",
+					zap.String("checker", r.Name),
+					zap.String("kind", r.Kind),
+					zap.Int("failures", failures),
+					zap.String("error", r.Error))
+			}
+		} else {
+			successes++
+			failures = 0
+		}
+
+		switch {
+		case failures >= s.unhealthyThreshold:
+			s.withdraw(s.ctx)
+		case successes >= s.healthyThreshold:
+			s.readmit(s.ctx)
+		}
+	}
+}
+
+// withdraw 摘除本实例节点，已摘除或未注册时不做任何事，失败时在下次检查时重试
+func (s *Service) withdraw(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.registered || s.withdrawn || s.unhealthyAction == UnhealthyActionNone {
+		return
+	}
+
+	for _, node := range s.nodes() {
+		var err error
+		if s.unhealthyAction == UnhealthyActionRemove {
+			err = s.apiClient.deleteNode(ctx, s.upstreamID, node.Key())
+		} else {
+			_, err = s.apiClient.setNodeWeight(ctx, s.upstreamID, node.Key(), 0)
+		}
+		if err != nil {
+			s.logger.Error("摘除节点失败", zap.String("node", node.Key()), zap.Error(err))
+			return
+		}
+	}
+
+	s.withdrawn = true
+	s.logger.Warn("健康检查连续失败，已摘除本实例节点",
+		zap.String("upstream_id", s.upstreamID),
+		zap.String("unhealthy_action", s.unhealthyAction))
+}
+
+// readmit 恢复被摘除的节点，未摘除或未注册时不做任何事，失败时在下次检查时重试
+func (s *Service) readmit(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.registered || !s.withdrawn {
+		return
+	}
+
+	for _, node := range s.nodes() {
+		if _, err := s.apiClient.ensureNode(ctx, s.upstream, s.name, node); err != nil {
+			s.logger.Error("恢复节点失败", zap.String("node", node.Key()), zap.Error(err))
+			return
+		}
+	}
+
+	s.withdrawn = false
+	s.logger.Info("健康检查已恢复，已重新加入本实例节点", zap.String("upstream_id", s.upstreamID))
+}